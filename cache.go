@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/goradd/moddoc/mod"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+)
+
+// cacheFileName is the name of the cache manifest written to the output directory.
+const cacheFileName = ".moddoc-cache.json"
+
+// cacheManifest records a hash of the inputs of each package page that was generated,
+// so that pages whose inputs have not changed can be skipped the next time.
+type cacheManifest struct {
+	// Version is the moddoc version that wrote the manifest.
+	Version string `json:"version"`
+	// Pages maps the file name of each generated page to the hash of its inputs.
+	Pages map[string]string `json:"pages"`
+
+	// common is the hash of the inputs shared by all pages.
+	common []byte
+}
+
+// newCacheManifest creates an empty manifest for the module m, which is documented together with modules.
+//
+// Every package page depends on the moddoc version, the package template, the load options and
+// the list of packages in all the modules, since those determine the links between pages.
+// With promoted methods or type checking, a page also shows what is declared in the other packages,
+// so every page depends on the source of all the packages.
+func newCacheManifest(m *mod.Module, modules []*mod.Module, opts *mod.LoadOptions, packageTemplate string) *cacheManifest {
+	c := &cacheManifest{
+		Version: moddocVersion(),
+		Pages:   make(map[string]string),
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "version %q\n", c.Version)
+	fmt.Fprintf(h, "template %q\n", packageTemplate)
+	o2 := *opts
+	o2.Jobs = 0 // does not change the output
+	o, _ := json.Marshal(o2)
+	fmt.Fprintf(h, "options %s\n", o)
+	fmt.Fprintf(h, "module %q %q %q\n", m.ImportPath, m.DirName, m.DocDir)
+
+	for _, m2 := range modules {
+		fmt.Fprintf(h, "linked module %q %q %q\n", m2.ImportPath, m2.DirName, m2.DocDir)
+		for _, p := range m2.PackageList {
+			fmt.Fprintf(h, "package %q %q %q\n", p.Path, p.Name, p.FileName)
+			if opts.PromotedMethods || opts.TypeCheck {
+				writeSourceHash(h, p)
+			}
+		}
+	}
+	c.common = h.Sum(nil)
+	return c
+}
+
+// packageHash returns the hash of all the inputs of the page for pkg.
+// If a source file cannot be read, a hash that will not match any previous one is returned.
+func (c *cacheManifest) packageHash(pkg *mod.Package) string {
+	h := sha256.New()
+	h.Write(c.common)
+	if !writeSourceHash(h, pkg) {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeSourceHash writes the source files of pkg to h. It returns false if a file could not be read.
+func writeSourceHash(h io.Writer, pkg *mod.Package) bool {
+	for _, fileName := range pkg.Files {
+		b, err := os.ReadFile(fileName)
+		if err != nil {
+			return false
+		}
+		fmt.Fprintf(h, "file %q %d\n", filepath.Base(fileName), len(b))
+		h.Write(b)
+	}
+	return true
+}
+
+// isCurrent returns true if the page in outDir was generated from inputs with the given hash.
+// It is safe to call on a nil manifest.
+func (c *cacheManifest) isCurrent(fileName string, hash string, outDir string) bool {
+	if c == nil || hash == "" || c.Version != moddocVersion() || c.Pages[fileName] != hash {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(outDir, fileName))
+	return err == nil
+}
+
+// readCacheManifest reads the manifest in outDir, returning nil if there is no usable manifest.
+func readCacheManifest(outDir string) *cacheManifest {
+	b, err := os.ReadFile(filepath.Join(outDir, cacheFileName))
+	if err != nil {
+		return nil
+	}
+	c := new(cacheManifest)
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil
+	}
+	return c
+}
+
+// write saves the manifest to outDir, and removes the pages listed in oldCache that are no longer generated.
+func (c *cacheManifest) write(outDir string, oldCache *cacheManifest) error {
+	if oldCache != nil {
+		for fileName := range oldCache.Pages {
+			if _, ok := c.Pages[fileName]; ok {
+				continue
+			}
+			if err := removePage(filepath.Join(outDir, filepath.Base(fileName))); err != nil {
+				return err
+			}
+		}
+	}
+	b, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outDir, cacheFileName), b, 0644)
+}
+
+// removePage removes the page at filePath, if it exists.
+func removePage(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing stale page: %w", err)
+	}
+	return nil
+}
+
+// moddocVersion returns the version of the running moddoc application.
+func moddocVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" || s.Key == "vcs.modified" {
+			v += " " + s.Key + "=" + s.Value
+		}
+	}
+	return v
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files, given by paths relative to dir, to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// generate runs the generate command with the given arguments.
+func generate(t *testing.T, args ...string) {
+	t.Helper()
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	g := addGenerateFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readPage(t *testing.T, outDir string, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(outDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestGenerate_cache(t *testing.T) {
+	src, out := t.TempDir(), t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n",
+		"b/b.go": "// Package b does things.\npackage b\n\n// G does things.\nfunc G() {}\n",
+	})
+	generate(t, "-i", src, "-o", out)
+
+	// Mark the page of b, so that it can be seen whether it was written again.
+	if err := os.WriteFile(filepath.Join(out, "b.html"), []byte("unchanged"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, src, map[string]string{
+		"a.go": "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n\n// H is new.\nfunc H() {}\n",
+	})
+	generate(t, "-i", src, "-o", out)
+	if page := readPage(t, out, "a.html"); !strings.Contains(page, "func H") {
+		t.Error("a.html was not regenerated after its source changed")
+	}
+	if page := readPage(t, out, "b.html"); page != "unchanged" {
+		t.Error("b.html was regenerated, but its inputs did not change")
+	}
+
+	if err := os.RemoveAll(filepath.Join(src, "b")); err != nil {
+		t.Fatal(err)
+	}
+	generate(t, "-i", src, "-o", out)
+	if _, err := os.Stat(filepath.Join(out, "b.html")); !os.IsNotExist(err) {
+		t.Errorf("the page of a removed package was not deleted: %v", err)
+	}
+}
+
+func TestGenerate_cachePromoted(t *testing.T) {
+	src, out := t.TempDir(), t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "// Package a does things.\npackage a\n\n// Base is embedded.\ntype Base struct{}\n\n// M does things.\nfunc (Base) M() {}\n",
+		"b/b.go": "// Package b does things.\npackage b\n\nimport \"example.com/a\"\n\n// T embeds a.Base.\ntype T struct {\n\ta.Base\n}\n",
+	})
+	generate(t, "-i", src, "-o", out, "-promoted")
+	if page := readPage(t, out, "b.html"); !strings.Contains(page, ">M<") {
+		t.Fatal("b.html does not list the promoted method M")
+	}
+
+	writeFiles(t, src, map[string]string{
+		"a.go": "// Package a does things.\npackage a\n\n// Base is embedded.\ntype Base struct{}\n\n// M does things.\nfunc (Base) M() {}\n\n// N does more.\nfunc (Base) N() {}\n",
+	})
+	generate(t, "-i", src, "-o", out, "-promoted")
+	if page := readPage(t, out, "b.html"); !strings.Contains(page, ">N<") {
+		t.Error("b.html was not regenerated after the embedded type changed")
+	}
+}
+
+func TestGenerate_staleModulePages(t *testing.T) {
+	src, out := t.TempDir(), t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "// Package a does things.\npackage a\n\n// F does things.\n//\n// Deprecated: use G.\nfunc F() {}\n\n// BUG(ann): F panics.\n",
+	})
+	generate(t, "-i", src, "-o", out)
+	for _, name := range []string{"deprecated.html", "notes.html"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Fatalf("%s was not written: %v", name, err)
+		}
+	}
+
+	writeFiles(t, src, map[string]string{
+		"a.go": "// Package a does things.\npackage a\n\n// G does things.\nfunc G() {}\n",
+	})
+	generate(t, "-i", src, "-o", out)
+	for _, name := range []string{"deprecated.html", "notes.html"} {
+		if _, err := os.Stat(filepath.Join(out, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed after its list became empty: %v", name, err)
+		}
+	}
+}
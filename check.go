@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+)
+
+func checkFlags(fs *flag.FlagSet) func(args []string) error {
+	modFlags := addModuleFlags(fs)
+	tmplFlags := addTemplateFlags(fs)
+	return func(args []string) error {
+		return check(modFlags, tmplFlags)
+	}
+}
+
+// check loads the module or workspace and renders every page without saving it, reporting the problems found.
+func check(modFlags *moduleFlags, tmplFlags *templateFlags) error {
+	cfg, err := modFlags.config()
+	if err != nil {
+		return err
+	}
+	t, err := tmplFlags.load(cfg)
+	if err != nil {
+		return err
+	}
+	d, err := modFlags.load(cfg)
+	if err != nil {
+		return err
+	}
+
+	var problems int
+	for _, m := range d.modules() {
+		problems += len(m.Warnings)
+		for _, w := range m.Warnings {
+			log.Print(w)
+		}
+
+		for _, pkg := range m.PackageList {
+			if err = t.pkg.Execute(io.Discard, pkg); err != nil {
+				log.Print(err)
+				problems++
+			}
+		}
+		if err = t.index.Execute(io.Discard, m); err != nil {
+			log.Print(err)
+			problems++
+		}
+		if len(m.Deprecated) > 0 {
+			if err = t.deprecated.Execute(io.Discard, m); err != nil {
+				log.Print(err)
+				problems++
+			}
+		}
+		if len(m.Notes) > 0 {
+			if err = t.notes.Execute(io.Discard, m); err != nil {
+				log.Print(err)
+				problems++
+			}
+		}
+	}
+	if d.workspace != nil {
+		if err = t.workspace.Execute(io.Discard, d.workspace); err != nil {
+			log.Print(err)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problem(s)", problems)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/goradd/moddoc/mod"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// configFileName is the name of the project configuration file that moddoc looks for next to the go.mod file.
+const configFileName = "moddoc.json"
+
+// config is the project configuration read from a moddoc.json file.
+//
+// Flags given on the command line override the values in the file.
+// Paths in the file are relative to the directory holding the file.
+type config struct {
+	// Include lists the packages to document. See [mod.LoadOptions].
+	Include []string `json:"include"`
+	// Exclude lists the packages to not document, like the -p flag.
+	Exclude []string `json:"exclude"`
+	// Internal documents the packages in "internal" directories.
+	Internal bool `json:"internal"`
+	// Testdata documents the packages in "testdata" directories.
+	Testdata bool `json:"testdata"`
+	// Vendor documents the packages in "vendor" directories.
+	Vendor bool `json:"vendor"`
+	// DotDirs documents the packages in directories whose names start with a ".".
+	DotDirs bool `json:"dotDirs"`
+	// IgnoreHide documents items that have a "doc: hide" command.
+	IgnoreHide bool `json:"ignoreHide"`
+	// Unexported documents the unexported declarations too, like the -unexported flag.
+	Unexported bool `json:"unexported"`
+	// PromotedMethods documents the methods inherited from embedded types, like the -promoted flag.
+	PromotedMethods bool `json:"promotedMethods"`
+	// TypeCheck type checks the module to find the interface implementations and references, and link the
+	// identifiers in code, like the -types flag.
+	TypeCheck bool `json:"typeCheck"`
+	// NoteMarkers are the markers of the notes to collect, like the -notes flag. The default is ["BUG"].
+	NoteMarkers []string `json:"noteMarkers"`
+	// SourceURL is the base URL of the source of the module, used to link notes to their source, like the -src flag.
+	SourceURL string `json:"sourceURL"`
+
+	// IndexTemplate is the path to a custom index page template, like the -iTmpl flag.
+	IndexTemplate string `json:"indexTemplate"`
+	// PackageTemplate is the path to a custom package page template, like the -pTmpl flag.
+	PackageTemplate string `json:"packageTemplate"`
+	// WorkspaceTemplate is the path to a custom workspace index page template, like the -wTmpl flag.
+	WorkspaceTemplate string `json:"workspaceTemplate"`
+	// DeprecatedTemplate is the path to a custom template for the page listing the deprecated items of a module,
+	// like the -dTmpl flag.
+	DeprecatedTemplate string `json:"deprecatedTemplate"`
+	// NotesTemplate is the path to a custom template for the page listing the notes of a module, like the -nTmpl flag.
+	NotesTemplate string `json:"notesTemplate"`
+	// Output is the output directory, like the -o flag.
+	Output string `json:"output"`
+	// InternalOutput is the output directory of a second set of documentation that includes the internal packages,
+	// like the -internalOut flag.
+	InternalOutput string `json:"internalOutput"`
+	// IndexFile is the name of the module index file. The default is "index.html".
+	IndexFile string `json:"indexFile"`
+
+	// ExternalURL is the base URL of the documentation of packages outside the module.
+	ExternalURL string `json:"externalURL"`
+	// ExternalURLs maps import path prefixes to the base URL of the documentation of those packages.
+	ExternalURLs map[string]string `json:"externalURLs"`
+
+	// GOOS is the target operating system used to select the source files to document, like the -goos flag.
+	GOOS string `json:"goos"`
+	// GOARCH is the target architecture used to select the source files to document, like the -goarch flag.
+	GOARCH string `json:"goarch"`
+	// Tags are the additional build tags used to select the source files to document, like the -tags flag.
+	Tags []string `json:"tags"`
+
+	// NestedModules documents the modules in subdirectories as separate modules, like the -nested flag.
+	NestedModules bool `json:"nestedModules"`
+
+	// Jobs is the maximum number of packages to parse or render at the same time, like the -j flag.
+	Jobs int `json:"jobs"`
+
+	// dir is the directory holding the configuration file.
+	dir string
+	// path is the path to the configuration file, or empty if there is none.
+	path string
+}
+
+// readConfig reads the configuration file at filePath.
+// If the file does not exist and is not required, an empty configuration is returned.
+func readConfig(filePath string, required bool) (*config, error) {
+	cfg := &config{dir: filepath.Dir(filePath)}
+	b, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return cfg, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading configuration file: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("error reading configuration file %s: %w", filePath, err)
+	}
+	cfg.path = filePath
+	return cfg, nil
+}
+
+// resolve returns the absolute path of a path given in the configuration file, or the empty string if p is empty.
+func (c *config) resolve(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(c.dir, p)
+}
+
+// loadOptions returns the module loading options given by the configuration.
+func (c *config) loadOptions() *mod.LoadOptions {
+	return &mod.LoadOptions{
+		Include:         c.Include,
+		Exclude:         c.Exclude,
+		Internal:        c.Internal,
+		Testdata:        c.Testdata,
+		Vendor:          c.Vendor,
+		DotDirs:         c.DotDirs,
+		IgnoreHide:      c.IgnoreHide,
+		Unexported:      c.Unexported,
+		PromotedMethods: c.PromotedMethods,
+		TypeCheck:       c.TypeCheck,
+		NoteMarkers:     c.NoteMarkers,
+		SourceURL:       c.SourceURL,
+		IndexFile:       c.IndexFile,
+		ExternalURL:     c.ExternalURL,
+		ExternalURLs:    c.ExternalURLs,
+		GOOS:            c.GOOS,
+		GOARCH:          c.GOARCH,
+		Tags:            c.Tags,
+		NestedModules:   c.NestedModules,
+		Jobs:            c.Jobs,
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		configFileName: `{"exclude": ["b"], "typeCheck": true, "packageTemplate": "tmpl/p.tmpl", "output": "/doc"}`,
+	})
+	cfg, err := readConfig(filepath.Join(dir, configFileName), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.resolve(cfg.PackageTemplate), filepath.Join(dir, "tmpl", "p.tmpl"); got != want {
+		t.Errorf("relative path resolved to %q, want %q", got, want)
+	}
+	if got := cfg.resolve(cfg.Output); got != "/doc" {
+		t.Errorf("absolute path resolved to %q", got)
+	}
+	opts := cfg.loadOptions()
+	if !opts.TypeCheck || len(opts.Exclude) != 1 || opts.Exclude[0] != "b" {
+		t.Errorf("load options do not match the configuration: %+v", opts)
+	}
+}
+
+func TestReadConfig_errors(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, configFileName)
+	if cfg, err := readConfig(missing, false); err != nil || cfg.path != "" {
+		t.Errorf("a missing optional file gave %v, %v", cfg, err)
+	}
+	if _, err := readConfig(missing, true); err == nil {
+		t.Error("a missing required file gave no error")
+	}
+
+	writeFiles(t, dir, map[string]string{configFileName: `{"outptu": "doc"}`})
+	if _, err := readConfig(missing, false); err == nil {
+		t.Error("an unknown field gave no error")
+	}
+}
+
+func TestConfig_flagsOverride(t *testing.T) {
+	src, cfgOut, flagOut := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, src, map[string]string{
+		configFileName: `{"output": "` + filepath.ToSlash(cfgOut) + `", "exclude": ["b"], "typeCheck": true}`,
+	})
+
+	tests := []struct {
+		args        []string
+		wantOut     string
+		wantExclude string
+		wantTypes   bool
+	}{
+		{[]string{"-i", src}, cfgOut, "b", true},
+		{[]string{"-i", src, "-o", flagOut, "-p", "c", "-types=false"}, flagOut, "c", false},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+		g := addGenerateFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		outDir, err := g.outDir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outDir != tt.wantOut {
+			t.Errorf("%v: output = %q, want %q", tt.args, outDir, tt.wantOut)
+		}
+		cfg, err := g.modFlags.config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := g.modFlags.options(cfg)
+		if len(opts.Exclude) != 1 || opts.Exclude[0] != tt.wantExclude || opts.TypeCheck != tt.wantTypes {
+			t.Errorf("%v: exclude = %v, typeCheck = %v, want [%s], %v", tt.args, opts.Exclude, opts.TypeCheck, tt.wantExclude, tt.wantTypes)
+		}
+	}
+}
+
+func TestModuleFlags_skippedDirs(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{configFileName: `{"vendor": true, "dotDirs": true}`})
+
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	f := addModuleFlags(fs)
+	if err := fs.Parse([]string{"-i", src, "-testdata", "-vendor=false"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := f.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts := f.options(cfg); !opts.Testdata || opts.Vendor || !opts.DotDirs {
+		t.Errorf("testdata = %v, vendor = %v, dotDirs = %v, want true, false, true", opts.Testdata, opts.Vendor, opts.DotDirs)
+	}
+}
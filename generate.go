@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/goradd/moddoc/internal/jobs"
+	"github.com/goradd/moddoc/mod"
+	"github.com/goradd/moddoc/tmpl"
+	"log"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// legacyCommand is run when moddoc is given flags without a command.
+// It accepts the flags of the generate command, plus the -t flag of earlier versions.
+var legacyCommand = &command{
+	name:  "moddoc",
+	short: "Writes the html documentation of a module to the output directory.",
+	flags: func(fs *flag.FlagSet) func(args []string) error {
+		outputTemplatesFlag := fs.Bool("t", false, "Instead of writing out the html, will write out the default index.tmpl file and package.tmpl to the directory specified in the -o flag.")
+		g := addGenerateFlags(fs)
+		return func(args []string) error {
+			if *outputTemplatesFlag {
+				outDir, err := g.outDir()
+				if err != nil {
+					return err
+				}
+				return writeTemplates(outDir)
+			}
+			return g.run()
+		}
+	},
+}
+
+// generateCommand holds the flags of the generate command.
+type generateCommand struct {
+	fs        *flag.FlagSet
+	modFlags  *moduleFlags
+	tmplFlags *templateFlags
+	outPath   *string
+	internal  *string
+	force     *bool
+}
+
+func addGenerateFlags(fs *flag.FlagSet) *generateCommand {
+	return &generateCommand{
+		fs:        fs,
+		modFlags:  addModuleFlags(fs),
+		tmplFlags: addTemplateFlags(fs),
+		outPath:   fs.String("o", "", "The output directory. Will use current working directory by default."),
+		internal:  fs.String("internalOut", "", "The output directory of a second set of documentation that includes the internal packages. The documentation written to the -o directory is not changed."),
+		force:     fs.Bool("force", false, "Regenerate every page, even the ones that have not changed since the last run."),
+	}
+}
+
+func generateFlags(fs *flag.FlagSet) func(args []string) error {
+	g := addGenerateFlags(fs)
+	return func(args []string) error {
+		return g.run()
+	}
+}
+
+// outDir returns the output directory given by the -o flag or the configuration file.
+func (g *generateCommand) outDir() (string, error) {
+	if !flagIsSet(g.fs, "o") {
+		cfg, err := g.modFlags.config()
+		if err != nil {
+			return "", err
+		}
+		if cfg.Output != "" {
+			return cfg.resolve(cfg.Output), nil
+		}
+	}
+	return absDir(*g.outPath)
+}
+
+// run loads the module or workspace and writes its documentation to the output directory.
+func (g *generateCommand) run() error {
+	cfg, err := g.modFlags.config()
+	if err != nil {
+		return err
+	}
+	outDir, err := g.outDir()
+	if err != nil {
+		return err
+	}
+	t, err := g.tmplFlags.load(cfg)
+	if err != nil {
+		return err
+	}
+
+	opts := g.modFlags.options(cfg)
+	if err = g.write(t, opts, outDir); err != nil {
+		return err
+	}
+
+	internalDir := cfg.resolve(cfg.InternalOutput)
+	if flagIsSet(g.fs, "internalOut") {
+		if internalDir, err = filepath.Abs(*g.internal); err != nil {
+			return err
+		}
+	}
+	if internalDir != "" {
+		opts2 := *opts
+		opts2.Internal = true
+		return g.write(t, &opts2, internalDir)
+	}
+	return nil
+}
+
+// write loads the module or workspace with the given options, and writes its documentation to outDir.
+func (g *generateCommand) write(t templates, opts *mod.LoadOptions, outDir string) error {
+	d, err := g.modFlags.loadWith(opts)
+	if err != nil {
+		return err
+	}
+	modules := d.modules()
+	for _, m2 := range modules {
+		for _, w := range m2.Warnings {
+			log.Print("warning: ", w)
+		}
+		if err = g.writeModule(m2, modules, t, opts, filepath.Join(outDir, filepath.FromSlash(m2.DocDir))); err != nil {
+			return err
+		}
+	}
+	if d.workspace != nil {
+		return execWorkspaceTemplate(t.workspace, d.workspace, filepath.Join(outDir, d.workspace.IndexFile))
+	}
+	return nil
+}
+
+// writeModule writes the documentation of the module m to outDir.
+// The modules are all the modules documented together with m, which the pages may link to.
+func (g *generateCommand) writeModule(m *mod.Module, modules []*mod.Module, t templates, opts *mod.LoadOptions, outDir string) error {
+	if err := createDirectoryIfNotExists(outDir); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	oldCache := readCacheManifest(outDir)
+	newCache := newCacheManifest(m, modules, opts, t.pkgSource)
+	hashes := make([]string, len(m.PackageList))
+
+	err := jobs.Run(context.Background(), len(m.PackageList), opts.Jobs, func(i int) error {
+		pkg := m.PackageList[i]
+		hashes[i] = newCache.packageHash(pkg)
+		if !*g.force && oldCache.isCurrent(pkg.FileName, hashes[i], outDir) {
+			return nil
+		}
+		return execPackageTemplate(t.pkg, pkg, outDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, pkg := range m.PackageList {
+		newCache.Pages[pkg.FileName] = hashes[i]
+	}
+	if err = newCache.write(outDir, oldCache); err != nil {
+		return err
+	}
+
+	// The deprecated and notes pages are only linked when they list something, so remove a page left by an
+	// earlier run when its list becomes empty.
+	if len(m.Deprecated) > 0 {
+		err = execModulePageTemplate(t.deprecated, m, filepath.Join(outDir, m.DeprecatedFile))
+	} else {
+		err = removePage(filepath.Join(outDir, m.DeprecatedFile))
+	}
+	if err != nil {
+		return err
+	}
+	if len(m.Notes) > 0 {
+		err = execModulePageTemplate(t.notes, m, filepath.Join(outDir, m.NotesFile))
+	} else {
+		err = removePage(filepath.Join(outDir, m.NotesFile))
+	}
+	if err != nil {
+		return err
+	}
+	return execModuleTemplate(t.index, m, filepath.Join(outDir, m.IndexFile))
+}
+
+func templatesFlags(fs *flag.FlagSet) func(args []string) error {
+	outPath := fs.String("o", "", "The output directory. Will use current working directory by default.")
+	return func(args []string) error {
+		outDir, err := absDir(*outPath)
+		if err != nil {
+			return err
+		}
+		return writeTemplates(outDir)
+	}
+}
+
+// writeTemplates writes the default templates to outDir.
+func writeTemplates(outDir string) error {
+	outDir, err := absDir(outDir)
+	if err != nil {
+		return err
+	}
+	if err = createDirectoryIfNotExists(outDir); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	return outputTemplates(outDir)
+}
+
+func createDirectoryIfNotExists(directoryPath string) error {
+	// Check if the directory already exists
+	if _, err := os.Stat(directoryPath); os.IsNotExist(err) {
+		// Directory does not exist, so create it
+		err := os.MkdirAll(directoryPath, 0755)
+		if err != nil {
+			return fmt.Errorf("failed to create directory: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func execPackageTemplate(t *template.Template, pkg *mod.Package, outDir string) error {
+	filePath := filepath.Join(outDir, pkg.FileName)
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("error opening file %s: %w", filePath, err)
+	}
+	defer file.Close()
+	if err = t.Execute(file, pkg); err != nil {
+		return fmt.Errorf("error executing package template for %s: %w", pkg.Path, err)
+	}
+	return nil
+}
+
+func execModuleTemplate(t *template.Template, m *mod.Module, filePath string) error {
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("error opening file %s: %w", filePath, err)
+	}
+	defer file.Close()
+	if err = t.Execute(file, m); err != nil {
+		return fmt.Errorf("error executing index template: %w", err)
+	}
+	return nil
+}
+
+// execModulePageTemplate writes a page about the module m, like the list of deprecated items, using the template t.
+func execModulePageTemplate(t *template.Template, m *mod.Module, filePath string) error {
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("error opening file %s: %w", filePath, err)
+	}
+	defer file.Close()
+	if err = t.Execute(file, m); err != nil {
+		return fmt.Errorf("error executing %s: %w", t.Name(), err)
+	}
+	return nil
+}
+
+func execWorkspaceTemplate(t *template.Template, ws *mod.Workspace, filePath string) error {
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("error opening file %s: %w", filePath, err)
+	}
+	defer file.Close()
+	if err = t.Execute(file, ws); err != nil {
+		return fmt.Errorf("error executing workspace template: %w", err)
+	}
+	return nil
+}
+
+func outputTemplates(outDir string) error {
+	filePath := filepath.Join(outDir, "index.tmpl")
+	if err := writeFile(tmpl.IndexTemplate, filePath); err != nil {
+		return err
+	}
+
+	filePath = filepath.Join(outDir, "package.tmpl")
+	if err := writeFile(tmpl.PackageTemplate, filePath); err != nil {
+		return err
+	}
+
+	filePath = filepath.Join(outDir, "workspace.tmpl")
+	if err := writeFile(tmpl.WorkspaceTemplate, filePath); err != nil {
+		return err
+	}
+
+	filePath = filepath.Join(outDir, "deprecated.tmpl")
+	if err := writeFile(tmpl.DeprecatedTemplate, filePath); err != nil {
+		return err
+	}
+
+	filePath = filepath.Join(outDir, "notes.tmpl")
+	if err := writeFile(tmpl.NotesTemplate, filePath); err != nil {
+		return err
+	}
+	return nil
+}
+
+func writeFile(inContent, outFile string) error {
+	return os.WriteFile(outFile, []byte(inContent), 0644)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_internalOut(t *testing.T) {
+	src, out, internalOut := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod":          "module example.com/a\n",
+		"a.go":            "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n",
+		"internal/x/x.go": "// Package x helps.\npackage x\n\n// G helps.\nfunc G() {}\n",
+	})
+	generate(t, "-i", src, "-o", out, "-internalOut", internalOut)
+
+	const badge = `<span class="badge internal">internal</span>`
+	if _, err := os.Stat(filepath.Join(out, "internal_x.html")); !os.IsNotExist(err) {
+		t.Errorf("the internal package was documented in the public output: %v", err)
+	}
+	if page := readPage(t, out, "index.html"); strings.Contains(page, "internal_x.html") || strings.Contains(page, badge) {
+		t.Error("the public index lists the internal package")
+	}
+	if page := readPage(t, out, "a.html"); !strings.Contains(page, "func F") {
+		t.Error("the public output does not document package a")
+	}
+
+	if page := readPage(t, internalOut, "index.html"); !strings.Contains(page, `href="internal_x.html">internal/x</a> `+badge) {
+		t.Errorf("the internal index does not list the internal package with its marker:\n%s", page)
+	}
+	if page := readPage(t, internalOut, "internal_x.html"); !strings.Contains(page, "Package x "+badge) {
+		t.Errorf("the internal package page has no internal marker:\n%s", page)
+	}
+	if page := readPage(t, internalOut, "a.html"); strings.Contains(page, badge) {
+		t.Error("package a is marked internal")
+	}
+}
+
+func TestGenerate_inputFile(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"go.mod": "module example.com/a\n", "a.go": "package a\n"})
+
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	g := addGenerateFlags(fs)
+	if err := fs.Parse([]string{"-i", filepath.Join(src, "go.mod"), "-o", t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	var usageErr usageError
+	if err := g.run(); !errors.As(err, &usageErr) {
+		t.Errorf("a file that is not a go.work file gave %v, want a usage error", err)
+	}
+}
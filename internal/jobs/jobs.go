@@ -0,0 +1,51 @@
+// Package jobs runs work on a bounded number of goroutines.
+package jobs
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// Run calls f for each index from 0 to count-1, running up to jobs calls at the same time.
+// If jobs is zero or less, the number of CPUs is used.
+//
+// The error returned is the one with the lowest index, so that it does not depend on scheduling.
+// If ctx is canceled, no more calls are started and the error of ctx is returned.
+func Run(ctx context.Context, count int, jobs int, f func(i int) error) error {
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	errs := make([]error, count)
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if errs[i] = ctx.Err(); errs[i] == nil {
+					errs[i] = f(i)
+				}
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var calls atomic.Int32
+	err := Run(context.Background(), 100, 4, func(i int) error {
+		calls.Add(1)
+		if i == 30 || i == 70 {
+			return fmt.Errorf("job %d", i)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "job 30" {
+		t.Errorf("got error %v, want the error of job 30", err)
+	}
+	if calls.Load() != 100 {
+		t.Errorf("got %d calls, want 100", calls.Load())
+	}
+}
+
+func TestRun_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Run(ctx, 100, 1, func(i int) error {
+		if i == 10 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
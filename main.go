@@ -2,14 +2,20 @@
 //
 // moddoc outputs static html documentation for the given source directory.
 //
+// The work is divided into commands, like "moddoc generate" and "moddoc serve". Run "moddoc help" to list them.
+// Running moddoc with only flags is the same as running the generate command, as in earlier versions.
+//
 // See the [moddoc/mod.Module] structure for the structure that is passed to the main template for processing.
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/goradd/moddoc/mod"
 	"github.com/goradd/moddoc/tmpl"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -17,152 +23,479 @@ import (
 	"text/template"
 )
 
-var packageTemplateFlag = flag.String("pTmpl", "", "The path to a custom package page template.")
-var indexTemplateFlag = flag.String("iTmpl", "", "The path to a custom index page template.")
-var sourcePathFlag = flag.String("i", "", "The path to the module directory. Should have a go.mod file. Will use current working directory by default.")
-var outPathFlag = flag.String("o", "", "The output directory. Will use current working directory by default.")
-var outputTemplatesFlag = flag.Bool("t", false, "Will write out the default index.tmpl file and package.tmpl. Will output to the directory specified in the -o flag.")
-var ignore = flag.String("p", "", "List of packages to ignore when generating documentation. Relative to root. Use : or ; to separate items. Do not start with a /.")
+// command is a moddoc subcommand.
+type command struct {
+	// name is the name used on the command line.
+	name string
+	// args describes the arguments that follow the flags, or is empty if the command takes no arguments.
+	args string
+	// short is a one line description of the command.
+	short string
+	// flags defines the flags of the command, and returns the function that runs the command
+	// with the arguments that remain after the flags are parsed.
+	flags func(fs *flag.FlagSet) func(args []string) error
+}
+
+var commands = []*command{
+	{"generate", "", "Writes the html documentation of a module to the output directory.", generateFlags},
+	{"serve", "", "Serves the documentation of a module, reloading pages when the source changes.", serveFlags},
+	{"templates", "", "Writes the default templates to the output directory, as a starting point for custom templates.", templatesFlags},
+	{"check", "", "Reports problems in the documentation comments of a module without writing any files.", checkFlags},
+	{"query", "[package[.name[.method]]]", "Prints the documentation of a package or an item in a package.", queryFlags},
+}
+
+// usageError is returned when the command line cannot be understood.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
 
 func main() {
-	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("moddoc: ")
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
 
-	srcDir := *sourcePathFlag
-	if srcDir == "" {
-		var err error
-		if srcDir, err = os.Getwd(); err != nil {
-			log.Fatal(err)
-		}
-	} else {
-		var err error
-		if srcDir, err = filepath.Abs(srcDir); err != nil {
-			log.Fatal(err)
+// run runs the command given by args and returns the exit status.
+//
+// The exit status is 0 on success, 1 if the command failed, and 2 if the command line is not valid.
+func run(args []string, stderr io.Writer) int {
+	var err error
+	switch {
+	case len(args) == 0 || strings.HasPrefix(args[0], "-"):
+		err = runCommand(legacyCommand, args, stderr)
+	case args[0] == "help":
+		err = help(args[1:], stderr)
+	default:
+		if cmd := findCommand(args[0]); cmd != nil {
+			err = runCommand(cmd, args[1:], stderr)
+		} else {
+			err = usageError{fmt.Sprintf("unknown command %q. Run 'moddoc help' for usage.", args[0])}
 		}
 	}
 
-	outDir := *outPathFlag
-	if outDir == "" {
-		var err error
-		if outDir, err = os.Getwd(); err != nil {
-			log.Fatal(err)
+	var usageErr usageError
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(stderr, "moddoc:", err)
+		return 2
+	default:
+		fmt.Fprintln(stderr, "moddoc:", err)
+		return 1
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
 		}
-	} else {
-		var err error
-		if outDir, err = filepath.Abs(outDir); err != nil {
-			log.Fatal(err)
+	}
+	return nil
+}
+
+// newFlagSet creates the flag set of the command, returning the flag set and the function that runs the command.
+func (cmd *command) newFlagSet(stderr io.Writer) (*flag.FlagSet, func(args []string) error) {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	runFunc := cmd.flags(fs)
+	fs.Usage = func() {
+		if cmd == legacyCommand {
+			fmt.Fprintf(stderr, "usage: moddoc <command> [flags]\n       moddoc [flags]\n\n")
+			printCommands(stderr)
+			fmt.Fprintf(stderr, "\nWithout a command, moddoc generates the documentation using these flags:\n")
+		} else {
+			fmt.Fprintf(stderr, "usage: moddoc %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.short)
 		}
+		fs.PrintDefaults()
 	}
+	return fs, runFunc
+}
 
-	if *outputTemplatesFlag {
-		err := outputTemplates(outDir)
-		if err != nil {
-			log.Fatal(err)
+func runCommand(cmd *command, args []string, stderr io.Writer) error {
+	fs, runFunc := cmd.newFlagSet(stderr)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
 		}
+		return usageError{err.Error()}
+	}
+	if cmd.args == "" && fs.NArg() > 0 {
+		return usageError{fmt.Sprintf("unexpected argument %q", fs.Arg(0))}
+	}
+	return runFunc(fs.Args())
+}
 
+// help prints the usage of moddoc, or of the command named in args.
+func help(args []string, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintf(stderr, "usage: moddoc <command> [flags]\n\n")
+		printCommands(stderr)
+		fmt.Fprintf(stderr, "\nRun 'moddoc help <command>' for the flags of a command.\n")
+		return nil
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return usageError{fmt.Sprintf("unknown command %q", args[0])}
 	}
+	fs, _ := cmd.newFlagSet(stderr)
+	fs.Usage()
+	return nil
+}
 
-	var err error
-	packageTemplate := template.New("packageTemplate")
-	if *packageTemplateFlag != "" {
-		packageTemplate, err = packageTemplate.ParseFiles(*packageTemplateFlag)
-		if err != nil {
-			log.Fatalf("error opening package template %s", *packageTemplateFlag)
-			return
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.short)
+	}
+}
+
+// absDir returns the absolute path of dir, or the current working directory if dir is empty.
+func absDir(dir string) (string, error) {
+	if dir == "" {
+		return os.Getwd()
+	}
+	return filepath.Abs(dir)
+}
+
+// flagIsSet returns true if the flag with the given name was given on the command line.
+func flagIsSet(fs *flag.FlagSet, name string) (set bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
-	} else {
-		packageTemplate, err = packageTemplate.Parse(tmpl.PackageTemplate)
-		if err != nil {
-			log.Fatalf("error parsing default package template")
-			return
+	})
+	return
+}
+
+// moduleFlags are the flags shared by the commands that load a module.
+type moduleFlags struct {
+	fs         *flag.FlagSet
+	sourcePath *string
+	configPath *string
+	ignore     *string
+	jobs       *int
+	goos       *string
+	goarch     *string
+	tags       *string
+	nested     *bool
+	internal   *bool
+	testdata   *bool
+	vendor     *bool
+	dotDirs    *bool
+	unexported *bool
+	promoted   *bool
+	notes      *string
+	sourceURL  *string
+	typeCheck  *bool
+}
+
+func addModuleFlags(fs *flag.FlagSet) *moduleFlags {
+	return &moduleFlags{
+		fs:         fs,
+		sourcePath: fs.String("i", "", "The path to the module directory, which should have a go.mod file, or to a go.work file. Will use current working directory by default."),
+		configPath: fs.String("config", "", "The path to the configuration file. Will use the "+configFileName+" file in the module directory by default, if there is one."),
+		ignore:     fs.String("p", "", "List of packages to ignore when generating documentation. Relative to root. Use : or ; to separate items. Do not start with a /. End an item with /... to also ignore the packages below it."),
+		jobs:       fs.Int("j", 0, "The maximum number of packages to parse or render at the same time. Defaults to the number of CPUs."),
+		goos:       fs.String("goos", "", "The target operating system used to select the source files to document. Defaults to the GOOS of the go environment."),
+		goarch:     fs.String("goarch", "", "The target architecture used to select the source files to document. Defaults to the GOARCH of the go environment."),
+		tags:       fs.String("tags", "", "A comma separated list of additional build tags used to select the source files to document."),
+		internal:   fs.Bool("internal", false, "Document the packages in internal directories."),
+		testdata:   fs.Bool("testdata", false, "Document the packages in testdata directories."),
+		vendor:     fs.Bool("vendor", false, "Document the packages in vendor directories."),
+		dotDirs:    fs.Bool("dotDirs", false, "Document the packages in directories whose names start with a \".\"."),
+		promoted:   fs.Bool("promoted", false, "Document the methods that types inherit from their embedded types."),
+		unexported: fs.Bool("unexported", false, "Document the unexported declarations too, for the maintainers of the module."),
+		notes:      fs.String("notes", "", "A comma separated list of the markers of the notes to collect, like BUG,TODO,SECURITY. Defaults to BUG."),
+		sourceURL:  fs.String("src", "", "The base URL of the source of the module, like https://github.com/org/repo/blob/main/, used to link notes to their source."),
+		typeCheck:  fs.Bool("types", false, "Type check the module to list the types that implement each interface and where each exported identifier is used, and to link the identifiers in code."),
+		nested:     fs.Bool("nested", false, "Document the modules in subdirectories that have their own go.mod file as separate modules, instead of skipping them."),
+	}
+}
+
+// srcDir returns the absolute path to the module directory, or to the directory of the go.work file.
+func (f *moduleFlags) srcDir() (string, error) {
+	dir, err := absDir(*f.sourcePath)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		if !isWorkFile(dir) {
+			return "", notWorkFileError(*f.sourcePath)
 		}
+		return filepath.Dir(dir), nil
 	}
+	return dir, nil
+}
 
-	indexTemplate := template.New("indexTemplate")
-	if *indexTemplateFlag != "" {
-		indexTemplate, err = indexTemplate.ParseFiles(*indexTemplateFlag)
-		if err != nil {
-			log.Fatalf("error opening index template %s", *indexTemplateFlag)
-			return
+// workFile returns the path to the go.work file to document, or the empty string if a single module is documented.
+func (f *moduleFlags) workFile() (string, error) {
+	p, err := absDir(*f.sourcePath)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(p); err == nil && !info.IsDir() {
+		if !isWorkFile(p) {
+			return "", notWorkFileError(*f.sourcePath)
 		}
-	} else {
-		indexTemplate, err = indexTemplate.Parse(tmpl.IndexTemplate)
+		return p, nil
+	}
+	if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+		return "", nil
+	}
+	if _, err := os.Stat(filepath.Join(p, "go.work")); err == nil {
+		return filepath.Join(p, "go.work"), nil
+	}
+	return "", nil
+}
+
+// isWorkFile returns true if the file at p is named like a go.work file.
+// The go command accepts any file name ending in ".work" as a workspace file.
+func isWorkFile(p string) bool {
+	return strings.HasSuffix(filepath.Base(p), ".work")
+}
+
+// notWorkFileError returns the error for a -i flag that names a file that is not a go.work file.
+func notWorkFileError(p string) error {
+	return usageError{fmt.Sprintf("-i %s: the path must be a module directory or a go.work file", p)}
+}
+
+// config reads the configuration file given by the -config flag, or the one in the module directory.
+func (f *moduleFlags) config() (*config, error) {
+	if *f.configPath != "" {
+		p, err := filepath.Abs(*f.configPath)
 		if err != nil {
-			log.Fatalf("error parsing default index template")
-			return
+			return nil, err
 		}
+		return readConfig(p, true)
 	}
+	srcDir, err := f.srcDir()
+	if err != nil {
+		return nil, err
+	}
+	return readConfig(filepath.Join(srcDir, configFileName), false)
+}
 
-	m := mod.NewModule(srcDir)
-
-	if err := createDirectoryIfNotExists(outDir); err != nil {
-		log.Fatalf("error creating output directory: %s", err)
-		return
+// options returns the module loading options given by the configuration file, overridden by the flags.
+func (f *moduleFlags) options(cfg *config) *mod.LoadOptions {
+	opts := cfg.loadOptions()
+	if flagIsSet(f.fs, "p") {
+		opts.Exclude = strings.FieldsFunc(*f.ignore, func(r rune) bool {
+			return r == ':' || r == ';'
+		})
+	}
+	if flagIsSet(f.fs, "j") {
+		opts.Jobs = *f.jobs
+	}
+	if flagIsSet(f.fs, "goos") {
+		opts.GOOS = *f.goos
+	}
+	if flagIsSet(f.fs, "goarch") {
+		opts.GOARCH = *f.goarch
+	}
+	if flagIsSet(f.fs, "tags") {
+		opts.Tags = strings.FieldsFunc(*f.tags, func(r rune) bool {
+			return r == ','
+		})
+	}
+	if flagIsSet(f.fs, "internal") {
+		opts.Internal = *f.internal
+	}
+	if flagIsSet(f.fs, "testdata") {
+		opts.Testdata = *f.testdata
+	}
+	if flagIsSet(f.fs, "vendor") {
+		opts.Vendor = *f.vendor
+	}
+	if flagIsSet(f.fs, "dotDirs") {
+		opts.DotDirs = *f.dotDirs
+	}
+	if flagIsSet(f.fs, "unexported") {
+		opts.Unexported = *f.unexported
+	}
+	if flagIsSet(f.fs, "promoted") {
+		opts.PromotedMethods = *f.promoted
+	}
+	if flagIsSet(f.fs, "notes") {
+		opts.NoteMarkers = strings.FieldsFunc(*f.notes, func(r rune) bool {
+			return r == ','
+		})
 	}
+	if flagIsSet(f.fs, "src") {
+		opts.SourceURL = *f.sourceURL
+	}
+	if flagIsSet(f.fs, "types") {
+		opts.TypeCheck = *f.typeCheck
+	}
+	if flagIsSet(f.fs, "nested") {
+		opts.NestedModules = *f.nested
+	}
+	return opts
+}
 
-	pkgs := strings.FieldsFunc(*ignore, func(r rune) bool {
-		return r == ':' || r == ';'
-	})
-	pkgSet := make(map[string]struct{})
-	for _, pkg := range pkgs {
-		pkgSet[pkg] = struct{}{}
+// docs is the documentation loaded by the module flags. It is either a module and the modules nested in it,
+// or the modules of a workspace.
+type docs struct {
+	module    *mod.Module
+	workspace *mod.Workspace
+}
+
+// modules returns all the modules that are documented.
+func (d *docs) modules() []*mod.Module {
+	if d.workspace != nil {
+		return d.workspace.Modules
 	}
+	return append([]*mod.Module{d.module}, d.module.Modules...)
+}
 
-	for k := range m.Packages {
-		if _, ok := pkgSet[k]; !ok {
-			execPackageTemplate(packageTemplate, m.Packages, k, outDir)
+// moduleByDocDir returns the module documented in docDir, or nil if there is none.
+func (d *docs) moduleByDocDir(docDir string) *mod.Module {
+	for _, m := range d.modules() {
+		if m.DocDir == docDir {
+			return m
 		}
 	}
+	return nil
+}
 
-	execModuleTemplate(indexTemplate, m, outDir)
+// load loads the module or workspace selected by the flags and configuration file.
+func (f *moduleFlags) load(cfg *config) (*docs, error) {
+	return f.loadWith(f.options(cfg))
 }
 
-func createDirectoryIfNotExists(directoryPath string) error {
-	// Check if the directory already exists
-	if _, err := os.Stat(directoryPath); os.IsNotExist(err) {
-		// Directory does not exist, so create it
-		err := os.MkdirAll(directoryPath, 0755)
+// loadWith loads the module or workspace selected by the flags, using the given options.
+func (f *moduleFlags) loadWith(opts *mod.LoadOptions) (*docs, error) {
+	workFile, err := f.workFile()
+	if err != nil {
+		return nil, err
+	}
+	if workFile != "" {
+		ws, err := mod.LoadWorkspace(context.Background(), workFile, opts)
 		if err != nil {
-			return fmt.Errorf("failed to create directory: %v", err)
+			return nil, err
 		}
+		return &docs{workspace: ws}, nil
 	}
+	srcDir, err := f.srcDir()
+	if err != nil {
+		return nil, err
+	}
+	m, err := mod.Load(context.Background(), srcDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &docs{module: m}, nil
+}
 
-	return nil
+// templateFlags are the flags that select custom templates.
+type templateFlags struct {
+	fs         *flag.FlagSet
+	index      *string
+	pkg        *string
+	workspace  *string
+	deprecated *string
+	notes      *string
 }
 
-func execPackageTemplate(t *template.Template, pkgs map[string]*mod.Package, pkgKey string, outDir string) {
-	pkg := pkgs[pkgKey]
-	filePath := filepath.Join(outDir, pkg.FileName)
-	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		log.Fatalf("error opening file %s", filePath)
+func addTemplateFlags(fs *flag.FlagSet) *templateFlags {
+	return &templateFlags{
+		fs:         fs,
+		index:      fs.String("iTmpl", "", "The path to a custom index page template."),
+		pkg:        fs.String("pTmpl", "", "The path to a custom package page template."),
+		workspace:  fs.String("wTmpl", "", "The path to a custom workspace index page template."),
+		deprecated: fs.String("dTmpl", "", "The path to a custom template for the page listing the deprecated items of a module."),
+		notes:      fs.String("nTmpl", "", "The path to a custom template for the page listing the notes of a module."),
 	}
-	defer file.Close()
-	err = t.Execute(file, pkg)
 }
 
-func execModuleTemplate(t *template.Template, m *mod.Module, outDir string) {
-	filePath := filepath.Join(outDir, "index.html")
-	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		log.Fatalf("error opening file %s", filePath)
-	}
-	defer file.Close()
-	err = t.Execute(file, m)
+// templates are the parsed templates used to render the pages.
+type templates struct {
+	index      *template.Template
+	pkg        *template.Template
+	workspace  *template.Template
+	deprecated *template.Template
+	notes      *template.Template
+	// pkgSource is the text the package template was parsed from.
+	pkgSource string
+}
+
+// templatePaths are the paths to the custom templates. An empty path selects the default template.
+type templatePaths struct {
+	index      string
+	pkg        string
+	workspace  string
+	deprecated string
+	notes      string
 }
 
-func outputTemplates(outDir string) error {
-	filePath := filepath.Join(outDir, "index.tmpl")
-	if err := writeFile(tmpl.IndexTemplate, filePath); err != nil {
-		return err
+// list returns all the paths.
+func (p templatePaths) list() []string {
+	return []string{p.index, p.pkg, p.workspace, p.deprecated, p.notes}
+}
+
+// paths returns the paths to the custom templates given by the flags or the configuration file.
+func (f *templateFlags) paths(cfg *config) (p templatePaths) {
+	p.index, p.pkg, p.workspace = cfg.resolve(cfg.IndexTemplate), cfg.resolve(cfg.PackageTemplate), cfg.resolve(cfg.WorkspaceTemplate)
+	p.deprecated, p.notes = cfg.resolve(cfg.DeprecatedTemplate), cfg.resolve(cfg.NotesTemplate)
+	if flagIsSet(f.fs, "iTmpl") {
+		p.index = *f.index
+	}
+	if flagIsSet(f.fs, "pTmpl") {
+		p.pkg = *f.pkg
+	}
+	if flagIsSet(f.fs, "wTmpl") {
+		p.workspace = *f.workspace
 	}
+	if flagIsSet(f.fs, "dTmpl") {
+		p.deprecated = *f.deprecated
+	}
+	if flagIsSet(f.fs, "nTmpl") {
+		p.notes = *f.notes
+	}
+	return
+}
 
-	filePath = filepath.Join(outDir, "package.tmpl")
-	if err := writeFile(tmpl.PackageTemplate, filePath); err != nil {
-		return err
+// load parses the templates selected by the flags and configuration file, or the default templates.
+func (f *templateFlags) load(cfg *config) (t templates, err error) {
+	p := f.paths(cfg)
+	if t.index, _, err = loadTemplate("indexTemplate", p.index, tmpl.IndexTemplate); err != nil {
+		return
 	}
-	return nil
+	if t.workspace, _, err = loadTemplate("workspaceTemplate", p.workspace, tmpl.WorkspaceTemplate); err != nil {
+		return
+	}
+	if t.deprecated, _, err = loadTemplate("deprecatedTemplate", p.deprecated, tmpl.DeprecatedTemplate); err != nil {
+		return
+	}
+	if t.notes, _, err = loadTemplate("notesTemplate", p.notes, tmpl.NotesTemplate); err != nil {
+		return
+	}
+	t.pkg, t.pkgSource, err = loadTemplate("packageTemplate", p.pkg, tmpl.PackageTemplate)
+	return
 }
 
-func writeFile(inContent, outFile string) error {
-	return os.WriteFile(outFile, []byte(inContent), 0644)
+// loadTemplate parses the template file at filePath, or the default template text if filePath is empty.
+// It returns the template and the source text it was parsed from.
+func loadTemplate(name string, filePath string, defaultText string) (t *template.Template, text string, err error) {
+	text = defaultText
+	if filePath != "" {
+		b, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("error opening template %s: %w", filePath, err)
+		}
+		text = string(b)
+	}
+	t, err = template.New(name).Parse(text)
+	if err != nil {
+		if filePath == "" {
+			filePath = "default " + name
+		}
+		return nil, "", fmt.Errorf("error parsing template %s: %w", filePath, err)
+	}
+	return t, text, nil
 }
@@ -0,0 +1,217 @@
+package mod
+
+import (
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"html"
+	"strings"
+)
+
+// recordAnchors records the anchor of each item documented on the package page, keyed by the name of the item,
+// or by "T.M" for a method M of type T. Items that go/doc groups with a type have anchors like "T.NewT".
+func (p *Package) recordAnchors() {
+	p.anchors = make(map[string]string)
+	add := func(name, anchor string) {
+		p.anchors[name] = anchor
+	}
+	for _, c := range p.Constants {
+		for _, n := range c.Names {
+			add(n, n)
+		}
+	}
+	for _, v := range p.Variables {
+		for _, n := range v.Names {
+			add(n, n)
+		}
+	}
+	for _, f := range p.Functions {
+		add(f.Name, f.Name)
+	}
+	for _, t := range p.Types {
+		add(t.Name, t.Name)
+		for _, c := range t.Constants {
+			for _, n := range c.Names {
+				add(n, t.Name+"."+n)
+			}
+		}
+		for _, v := range t.Variables {
+			for _, n := range v.Names {
+				add(n, t.Name+"."+n)
+			}
+		}
+		for _, f := range t.Functions {
+			add(f.Name, t.Name+"."+f.Name)
+		}
+		for _, m := range t.Methods {
+			add(t.Name+"."+m.Name, t.Name+"."+m.Name)
+		}
+		for _, m := range t.InterfaceMethods {
+			add(t.Name+"."+m.Name, t.Name+"."+m.Name)
+		}
+	}
+}
+
+// linkCode sets the CodeHtml of the items of the package. If the package was type checked,
+// the identifiers are linked to their documentation.
+//
+// It must be called after the anchors of all the packages of the module set are recorded, and after the
+// inherited methods are added, as those may be declared in other packages.
+func (p *Package) linkCode() {
+	for i := range p.Constants {
+		p.Constants[i].CodeHtml = p.codeHtml(p.Constants[i].decl, p.Constants[i].Code)
+	}
+	for i := range p.Variables {
+		p.Variables[i].CodeHtml = p.codeHtml(p.Variables[i].decl, p.Variables[i].Code)
+	}
+	for i := range p.Functions {
+		p.Functions[i].CodeHtml = p.codeHtml(p.Functions[i].decl, p.Functions[i].Code)
+	}
+	for _, t := range p.Types {
+		t.CodeHtml = p.codeHtml(t.decl, t.Code)
+		for i := range t.Constants {
+			t.Constants[i].CodeHtml = p.codeHtml(t.Constants[i].decl, t.Constants[i].Code)
+		}
+		for i := range t.Variables {
+			t.Variables[i].CodeHtml = p.codeHtml(t.Variables[i].decl, t.Variables[i].Code)
+		}
+		for i := range t.Functions {
+			t.Functions[i].CodeHtml = p.codeHtml(t.Functions[i].decl, t.Functions[i].Code)
+		}
+		for i := range t.Methods {
+			t.Methods[i].CodeHtml = p.codeHtml(t.Methods[i].node(), t.Methods[i].Code)
+		}
+		for i := range t.InterfaceMethods {
+			t.InterfaceMethods[i].CodeHtml = p.codeHtml(t.InterfaceMethods[i].node(), t.InterfaceMethods[i].Code)
+		}
+		for _, g := range t.Inherited {
+			for i := range g.Methods {
+				g.Methods[i].CodeHtml = p.codeHtml(g.Methods[i].node(), g.Methods[i].Code)
+			}
+		}
+	}
+}
+
+// codeHtml returns the code generated from node as HTML. If the package that declares node was type checked,
+// the identifiers are linked to their documentation, relative to the page of p.
+//
+// The printer writes the identifiers of node in the order they are found in the syntax tree,
+// so the identifiers scanned from the code are matched with the identifiers of the tree in order.
+func (p *Package) codeHtml(node ast.Node, code string) string {
+	var info *types.Info
+	if c := p.Module.set.checker; c != nil && node != nil {
+		info = c.info(node.Pos())
+	}
+	if info == nil {
+		return html.EscapeString(code)
+	}
+	var idents []*ast.Ident
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			idents = append(idents, id)
+		}
+		return true
+	})
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(code))
+	var s scanner.Scanner
+	s.Init(file, []byte(code), nil, 0)
+
+	var buf strings.Builder
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.IDENT {
+			continue
+		}
+		if len(idents) == 0 || idents[0].Name != lit {
+			return html.EscapeString(code) // the code does not match the tree
+		}
+		id := idents[0]
+		idents = idents[1:]
+		url := p.identURL(info, id)
+		if url == "" {
+			continue
+		}
+		offset := file.Offset(pos)
+		buf.WriteString(html.EscapeString(code[last:offset]))
+		buf.WriteString(`<a href="` + html.EscapeString(url) + `">` + lit + `</a>`)
+		last = offset + len(lit)
+	}
+	buf.WriteString(html.EscapeString(code[last:]))
+	return buf.String()
+}
+
+// identURL returns the link to the documentation of the object that the identifier refers to, or the empty string
+// if the object is not documented, or is declared locally, like a parameter.
+func (p *Package) identURL(info *types.Info, id *ast.Ident) string {
+	obj := info.Uses[id]
+	if obj == nil {
+		return "" // a declaration, or an unresolved name
+	}
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		importPath := pkgName.Imported().Path()
+		if url, ok := p.Module.set.packageURL(p.Module, importPath); ok {
+			return url
+		}
+		return p.Module.opts.externalURL(importPath)
+	}
+	if obj.Pkg() == nil {
+		if _, ok := obj.(*types.TypeName); ok {
+			return p.Module.opts.externalURL("builtin") + "#" + obj.Name()
+		}
+		return ""
+	}
+
+	name := obj.Name()
+	if f, ok := obj.(*types.Func); ok && f.Type().(*types.Signature).Recv() != nil {
+		recv := receiverName(f.Type().(*types.Signature).Recv().Type())
+		if recv == "" {
+			return ""
+		}
+		name = recv + "." + name
+	} else if obj.Parent() != obj.Pkg().Scope() {
+		return "" // a local object, field or type parameter
+	}
+	return p.objectURL(obj.Pkg().Path(), name)
+}
+
+// objectURL returns the link to the documentation of the package level object, or method like "T.M",
+// declared in the package with the given import path.
+func (p *Package) objectURL(importPath string, name string) string {
+	if importPath == p.ImportPath {
+		if anchor, ok := p.anchors[name]; ok {
+			return "#" + anchor
+		}
+		return ""
+	}
+	if _, _, _, ok := p.Module.set.lookup(importPath); ok {
+		p2 := p.Module.set.findPackage(importPath)
+		if p2 == nil {
+			return ""
+		}
+		anchor, ok := p2.anchors[name]
+		if !ok {
+			return ""
+		}
+		url, _ := p.Module.set.packageURL(p.Module, importPath)
+		return url + "#" + anchor
+	}
+	return p.Module.opts.externalURL(importPath) + "#" + name
+}
+
+// receiverName returns the name of the named type of a method receiver, or the empty string if there is none.
+func receiverName(t types.Type) string {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package mod
+
+import (
+	"strings"
+)
+
+// DeprecatedItem is an entry in the list of the deprecated APIs of a module.
+type DeprecatedItem struct {
+	// Package is the package that declares the item.
+	Package *Package
+	// Kind is the kind of item, like "package", "const", "var", "func", "type", "method" or "field".
+	Kind string
+	// Name is the name of the item as it is shown in the package documentation, like "T.M" for a method.
+	Name string
+	// Message is the text of the deprecation paragraph, after "Deprecated:".
+	Message string
+	// URL is the link to the item in the documentation of the package, relative to the module directory.
+	URL string
+}
+
+// deprecation returns true and the deprecation message if the comment text has a paragraph that starts with
+// "Deprecated:", following the Go convention.
+func deprecation(text string) (bool, string) {
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if msg, ok := strings.CutPrefix(para, "Deprecated:"); ok {
+			return true, strings.Join(strings.Fields(msg), " ")
+		}
+	}
+	return false, ""
+}
+
+// collectDeprecated lists the deprecated items of all the packages of the module, in the order of the package list.
+func (m *Module) collectDeprecated() {
+	m.Deprecated = nil
+	for _, p := range m.PackageList {
+		add := func(deprecated bool, kind, name, msg, anchor string) {
+			if !deprecated {
+				return
+			}
+			url := p.FileName
+			if anchor != "" {
+				url += "#" + anchor
+			}
+			m.Deprecated = append(m.Deprecated, DeprecatedItem{Package: p, Kind: kind, Name: name, Message: msg, URL: url})
+		}
+
+		add(p.Deprecated, "package", p.ImportPath, p.DeprecatedMessage, "")
+		for _, c := range p.Constants {
+			add(c.Deprecated, "const", strings.Join(c.Names, ", "), c.DeprecatedMessage, c.Names[0])
+		}
+		for _, v := range p.Variables {
+			add(v.Deprecated, "var", strings.Join(v.Names, ", "), v.DeprecatedMessage, v.Names[0])
+		}
+		for _, f := range p.Functions {
+			add(f.Deprecated, "func", f.Name, f.DeprecatedMessage, f.Name)
+		}
+		for _, t := range p.Types {
+			add(t.Deprecated, "type", t.Name, t.DeprecatedMessage, t.Name)
+			for _, f := range t.Fields {
+				add(f.Deprecated, "field", t.Name+"."+f.Name, f.DeprecatedMessage, t.Name+"."+f.Name)
+			}
+			for _, f := range t.InterfaceMethods {
+				add(f.Deprecated, "method", t.Name+"."+f.Name, f.DeprecatedMessage, t.Name+"."+f.Name)
+			}
+			for _, c := range t.Constants {
+				add(c.Deprecated, "const", strings.Join(c.Names, ", "), c.DeprecatedMessage, t.Name+"."+c.Names[0])
+			}
+			for _, v := range t.Variables {
+				add(v.Deprecated, "var", strings.Join(v.Names, ", "), v.DeprecatedMessage, t.Name+"."+v.Names[0])
+			}
+			for _, f := range t.Functions {
+				add(f.Deprecated, "func", f.Name, f.DeprecatedMessage, t.Name+"."+f.Name)
+			}
+			for _, f := range t.Methods {
+				add(f.Deprecated, "method", t.Name+"."+f.Name, f.DeprecatedMessage, t.Name+"."+f.Name)
+			}
+		}
+	}
+}
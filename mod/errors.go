@@ -0,0 +1,65 @@
+package mod
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoModFile is returned when the directory given to [Load] does not contain a go.mod file.
+var ErrNoModFile = errors.New("go.mod file not found")
+
+// ErrNoWorkFile is returned when the path given to [LoadWorkspace] is not a go.work file or a directory containing one.
+var ErrNoWorkFile = errors.New("go.work file not found")
+
+// ErrNoPackages is returned when a module does not contain any directories to document.
+var ErrNoPackages = errors.New("no packages found")
+
+// ModFileError reports a go.mod or go.work file that could not be read or parsed.
+type ModFileError struct {
+	// Path is the path to the go.mod or go.work file.
+	Path string
+	Err  error
+}
+
+func (e *ModFileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *ModFileError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError reports a Go source file that could not be parsed.
+type ParseError struct {
+	// File is the path to the file with the error.
+	File string
+	// Line is the line number of the first error found, or zero if not known.
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("parse error: %s:%d: %s", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("parse error: %s: %s", e.File, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// SourceError reports a source file or directory that could not be read.
+type SourceError struct {
+	// Path is the path to the file or directory that could not be read.
+	Path string
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("could not read %s: %s", e.Path, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,61 @@
+package mod
+
+import (
+	"bytes"
+	"go/ast"
+	"go/doc"
+	"go/format"
+	"go/printer"
+	"regexp"
+	"strings"
+)
+
+// outputPrefix matches the start of the output comment of an example.
+var outputPrefix = regexp.MustCompile(`(?im)^[ \t]*//[ \t]*(unordered )?output:`)
+
+func (p *Package) parseExamples(examples []*doc.Example) (items []Example) {
+	for _, e := range examples {
+		var e2 Example
+		e2.Name = e.Name
+		e2.Suffix = e.Suffix
+		cmt, flags := parseCommentFlags(e.Doc)
+		p.checkFlags("example "+e.Name, flags)
+		if p.Module.opts.hidden(flags) {
+			continue
+		}
+		e2.Flags = flags
+		e2.doc = cmt
+		e2.CommentHtml = p.parseHtmlComment(cmt)
+		e2.Code = p.exampleCode(e)
+		e2.Output = e.Output
+		e2.Unordered = e.Unordered
+		e2.EmptyOutput = e.EmptyOutput
+		items = append(items, e2)
+	}
+	return
+}
+
+// exampleCode returns the formatted code of an example.
+//
+// The code of an example function is its body, without the surrounding braces and the output comment.
+func (p *Package) exampleCode(e *doc.Example) string {
+	var buf bytes.Buffer
+	node := &printer.CommentedNode{Node: e.Code, Comments: e.Comments}
+	if err := format.Node(&buf, p.Fset, node); err != nil {
+		return ""
+	}
+	code := buf.String()
+	if _, ok := e.Code.(*ast.BlockStmt); ok {
+		code = strings.TrimSuffix(strings.TrimPrefix(code, "{"), "}")
+		// the body is indented one tab
+		lines := strings.Split(code, "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimPrefix(l, "\t")
+		}
+		code = strings.Join(lines, "\n")
+	}
+	if loc := outputPrefix.FindStringIndex(code); loc != nil {
+		code = code[:loc[0]]
+	}
+	return strings.TrimSpace(code) + "\n"
+}
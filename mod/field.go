@@ -0,0 +1,115 @@
+package mod
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strconv"
+)
+
+// Field represents a field of a struct type.
+//
+// A field declaration that declares several names, like "X, Y int", is represented by a Field for each name.
+type Field struct {
+	// Name is the name of the field. The name of an embedded field is the name of its type,
+	// without the package name or pointer.
+	Name string
+	// Type is the type expression of the field.
+	Type string
+	// Tag is the value of the field tag, without the quotes, or the empty string if there is no tag.
+	Tag string
+	// Embedded is true if the field is an embedded field.
+	Embedded    bool
+	CommentHtml string
+	Flags       map[string]string
+	Exported    bool
+
+	// Deprecated is true if the comment has a paragraph that starts with "Deprecated:".
+	Deprecated bool
+	// DeprecatedMessage is the text of the deprecation paragraph, after "Deprecated:".
+	DeprecatedMessage string
+
+	doc string
+}
+
+func (f Field) comment() string { return f.doc }
+
+// parseFields returns the fields of the struct type t, and the type declaration without the hidden fields.
+func (p *Package) parseFields(t *Type, decl *ast.GenDecl, st *ast.StructType) (fields []Field, decl2 *ast.GenDecl) {
+	var list []*ast.Field
+	hidden := false
+	for _, field := range st.Fields.List {
+		cmt, flags := parseFieldFlags(field)
+
+		var f Field
+		f.Type = p.exprCode(field.Type)
+		if field.Tag != nil {
+			f.Tag, _ = strconv.Unquote(field.Tag.Value)
+		}
+		f.Flags = flags
+		f.doc = cmt
+		f.Deprecated, f.DeprecatedMessage = deprecation(cmt)
+
+		names := field.Names
+		if len(names) == 0 {
+			f.Embedded = true
+			names = []*ast.Ident{embeddedName(field.Type)}
+		}
+		if names[0] == nil {
+			continue // not valid Go
+		}
+		p.checkFlags("field "+t.Name+"."+names[0].Name, flags)
+		if p.Module.opts.hidden(flags) {
+			hidden = true
+			continue
+		}
+		list = append(list, field)
+
+		f.CommentHtml = p.parseHtmlComment(cmt)
+		for _, n := range names {
+			f.Name = n.Name
+			f.Exported = token.IsExported(n.Name)
+			fields = append(fields, f)
+		}
+	}
+
+	if !hidden {
+		return fields, decl
+	}
+	// Document a copy of the declaration without the hidden fields.
+	fieldList := *st.Fields
+	fieldList.List = list
+	st2 := *st
+	st2.Fields = &fieldList
+	return fields, declWithType(decl, &st2)
+}
+
+// embeddedName returns the identifier that names an embedded field with the given type expression.
+func embeddedName(expr ast.Expr) *ast.Ident {
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			return e.Sel
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+// exprCode returns the formatted source of an expression.
+func (p *Package) exprCode(expr ast.Expr) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, p.Fset, expr); err != nil {
+		return ""
+	}
+	return buf.String()
+}
@@ -0,0 +1,252 @@
+package mod
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// addInherited adds m to the methods inherited from the type from.
+func (t *Type) addInherited(from string, url string, m Method) {
+	for i := range t.Inherited {
+		if t.Inherited[i].From == from {
+			t.Inherited[i].Methods = append(t.Inherited[i].Methods, m)
+			return
+		}
+	}
+	t.Inherited = append(t.Inherited, InheritedMethods{From: from, URL: url, Methods: []Method{m}})
+}
+
+// typeURL returns the link to the documentation of the type named by a receiver, like "*T", in this package,
+// or the empty string if the type is not documented.
+func (p *Package) typeURL(recv string) string {
+	name := strings.TrimPrefix(recv, "*")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	if _, ok := p.types[name]; ok {
+		return "#" + name
+	}
+	return ""
+}
+
+// addInheritedMethods finds the methods the struct types of the package inherit from their embedded types.
+//
+// The embedded types are searched breadth first, through the types of every package of the module set and through
+// embedded interfaces. As in Go, a method at a shallower depth shadows the methods with the same name at deeper ones,
+// and a name found more than once at its shallowest depth is ambiguous, so it is not promoted.
+//
+// The embedded fields of a type that are not documented are removed from its declaration by go/doc, so the methods
+// promoted through them are taken from go/doc instead.
+func (p *Package) addInheritedMethods() {
+	for _, t := range p.Types {
+		var st *ast.StructType
+		if t.spec != nil {
+			st, _ = t.spec.Type.(*ast.StructType)
+		}
+		if st == nil {
+			for _, m := range t.promoted {
+				t.addInherited(m.EmbeddedType, p.typeURL(m.EmbeddedType), m)
+			}
+			continue
+		}
+
+		found := p.hiddenPromotions(p, t, 0)
+		visited := map[*Type]int{t: 0}
+		level := p.embeddedFields(st.Fields.List)
+		for depth := 1; len(level) > 0; depth++ {
+			var next []embeddedField
+			for _, e := range level {
+				f, n := p.embeddedMethods(e, depth, visited)
+				found = append(found, f...)
+				next = append(next, n...)
+			}
+			level = next
+		}
+		t.addPromotions(found)
+	}
+}
+
+// promotion is a method that may be promoted to a type, found at the given depth of embedding.
+type promotion struct {
+	from  string // the embedded type that declares the method, as named in the inherited method list
+	url   string
+	depth int
+	m     Method
+}
+
+// addPromotions adds the methods of found that are promoted to t to its inherited methods.
+func (t *Type) addPromotions(found []promotion) {
+	type selector struct{ depth, count int }
+	selectors := make(map[string]selector)
+	for _, m := range t.Methods {
+		selectors[m.Name] = selector{0, 1}
+	}
+	// go/doc reports a method of an undocumented type to each type it is promoted through, so the same method
+	// may be found more than once.
+	type key struct {
+		from, name string
+		depth      int
+	}
+	seen := make(map[key]bool)
+	for _, f := range found {
+		key := key{f.from, f.m.Name, f.depth}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		sel, ok := selectors[f.m.Name]
+		switch {
+		case !ok || f.depth < sel.depth:
+			selectors[f.m.Name] = selector{f.depth, 1}
+		case f.depth == sel.depth:
+			sel.count++
+			selectors[f.m.Name] = sel
+		}
+	}
+
+	for _, f := range found {
+		sel := selectors[f.m.Name]
+		if f.depth != sel.depth || sel.count != 1 {
+			continue // the method is shadowed or ambiguous
+		}
+		sel.count = 0 // add it once
+		selectors[f.m.Name] = sel
+		m := f.m
+		m.Receiver = t.Name
+		m.EmbeddedType = f.from
+		m.Level = f.depth
+		t.addInherited(f.from, f.url, m)
+	}
+}
+
+// embeddedField is an embedded field, or an embedded interface, of a type declared in the package p.
+type embeddedField struct {
+	p     *Package
+	field *ast.Field
+}
+
+// embeddedFields returns the fields of list that are embedded and not hidden.
+func (p *Package) embeddedFields(list []*ast.Field) (fields []embeddedField) {
+	for _, field := range list {
+		if len(field.Names) > 0 {
+			continue
+		}
+		if _, flags := parseFieldFlags(field); p.Module.opts.hidden(flags) {
+			continue
+		}
+		fields = append(fields, embeddedField{p, field})
+	}
+	return
+}
+
+// embeddedMethods returns the methods of the type of the embedded field e, which is found at the given depth,
+// and the embedded fields of that type, which are searched at the next depth.
+// Visited records the depth at which each type was found. A type found again at a deeper depth is not searched again.
+func (p *Package) embeddedMethods(e embeddedField, depth int, visited map[*Type]int) (found []promotion, next []embeddedField) {
+	expr := e.field.Type
+	var star string
+	if x, ok := expr.(*ast.StarExpr); ok {
+		expr, star = x.X, "*"
+	}
+	switch x := expr.(type) {
+	case *ast.IndexExpr:
+		expr = x.X
+	case *ast.IndexListExpr:
+		expr = x.X
+	}
+
+	var p2 *Package
+	var name, from string
+	switch x := expr.(type) {
+	case *ast.Ident:
+		p2, name, from = e.p, x.Name, x.Name
+	case *ast.SelectorExpr:
+		id, ok := x.X.(*ast.Ident)
+		if !ok {
+			return nil, nil
+		}
+		if importPath := e.p.importPath(e.field.Pos(), id.Name); importPath != "" {
+			p2 = p.Module.set.findPackage(importPath)
+		}
+		name, from = x.Sel.Name, id.Name+"."+x.Sel.Name
+	}
+	if p2 == nil {
+		return nil, nil
+	}
+	t2 := p2.types[name]
+	if t2 == nil || t2.spec == nil {
+		return nil, nil
+	}
+	if d, ok := visited[t2]; ok && d < depth {
+		return nil, nil
+	}
+	visited[t2] = depth
+	if e.p != p && p2 != p {
+		from = p2.Name + "." + name // the import name is only known in the file that embeds the type
+	}
+	from = star + from
+
+	url := "#" + name
+	if p2 != p {
+		url, _ = p.Module.set.packageURL(p.Module, p2.ImportPath)
+		url += "#" + name
+	}
+	methods := t2.Methods
+	switch st := t2.spec.Type.(type) {
+	case *ast.InterfaceType:
+		methods = t2.InterfaceMethods
+		next = p2.embeddedFields(st.Methods.List)
+	case *ast.StructType:
+		next = p2.embeddedFields(st.Fields.List)
+	}
+	for _, m := range methods {
+		found = append(found, promotion{from: from, url: url, depth: depth, m: m})
+	}
+	return append(found, p.hiddenPromotions(p2, t2, depth)...), next
+}
+
+// hiddenPromotions returns the methods go/doc found promoted to the type t of package p2, which is found at the
+// given depth, from the embedded types that are not documented.
+func (p *Package) hiddenPromotions(p2 *Package, t *Type, depth int) (found []promotion) {
+	for _, m := range t.promoted {
+		if p2.typeURL(m.EmbeddedType) != "" {
+			continue // a documented type, which is searched instead
+		}
+		from := m.EmbeddedType
+		if p2 != p {
+			from = p2.Name + "." + strings.TrimPrefix(from, "*")
+		}
+		found = append(found, promotion{from: from, depth: depth + m.Level, m: m})
+	}
+	return
+}
+
+// importPath returns the path of the package imported with the given name by the file holding pos,
+// or the empty string if there is no such import.
+func (p *Package) importPath(pos token.Pos, name string) string {
+	fileName := p.Fset.Position(pos).Filename
+	for _, f := range p.astFiles {
+		if p.Fset.Position(f.Pos()).Filename != fileName {
+			continue
+		}
+		for _, spec := range f.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			localName := path.Base(importPath)
+			if spec.Name != nil {
+				localName = spec.Name.Name
+			} else if n, ok := p.Module.set.packageName(importPath); ok {
+				localName = n
+			}
+			if localName == name {
+				return importPath
+			}
+		}
+	}
+	return ""
+}
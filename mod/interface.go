@@ -0,0 +1,215 @@
+package mod
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// Kinds of interface elements.
+const (
+	// ElementEmbedded is an embedded interface, like "io.Reader".
+	ElementEmbedded = "embedded"
+	// ElementType is a single type that is not an interface, like "int".
+	ElementType = "type"
+	// ElementApproximation is a single type with a "~", like "~int".
+	ElementApproximation = "approximation"
+	// ElementUnion is a union of terms, like "~int | ~string".
+	ElementUnion = "union"
+)
+
+// InterfaceElement represents an embedded interface, or a type constraint element, in an interface type.
+type InterfaceElement struct {
+	// Code is the source of the element, like "io.Reader" or "~int | ~string".
+	Code string
+	// URL is the link to the documentation of an embedded interface, or the empty string if there is none.
+	URL string
+	// Kind is one of ElementEmbedded, ElementType, ElementApproximation or ElementUnion.
+	// An interface with an element that is not embedded can only be used as a type constraint.
+	Kind string
+	// Terms are the terms of a union, or the single term of the other kinds of elements.
+	Terms       []Term
+	CommentHtml string
+	Flags       map[string]string
+
+	doc string
+}
+
+// Term is a term of a type constraint element.
+type Term struct {
+	// Tilde is true if the term is an approximation, like "~int", which includes all types with the underlying type.
+	Tilde bool
+	// Type is the type of the term, without the "~".
+	Type string
+	// URL is the link to the documentation of the type, if it is a named type.
+	URL string
+}
+
+func (e InterfaceElement) comment() string { return e.doc }
+
+// parseInterface returns the methods and elements of the interface type t,
+// and the type declaration without the hidden methods and elements.
+func (p *Package) parseInterface(t *Type, decl *ast.GenDecl, it *ast.InterfaceType) (methods []Method, elements []InterfaceElement, decl2 *ast.GenDecl) {
+	var list []*ast.Field
+	hidden := false
+	for _, field := range it.Methods.List {
+		cmt, flags := parseFieldFlags(field)
+
+		if ft, ok := field.Type.(*ast.FuncType); ok && len(field.Names) > 0 {
+			name := field.Names[0].Name
+			p.checkFlags("method "+t.Name+"."+name, flags)
+			if p.Module.opts.hidden(flags) {
+				hidden = true
+				continue
+			}
+			list = append(list, field)
+			var m Method
+			m.Name = name
+			m.Exported = token.IsExported(name)
+			m.Flags = flags
+			m.doc = cmt
+			m.Deprecated, m.DeprecatedMessage = deprecation(cmt)
+			m.CommentHtml = p.parseHtmlComment(cmt)
+			m.Receiver = t.Name
+			m.Signature = p.newSignature(nil, ft)
+			m.Code = name + strings.TrimPrefix(p.exprCode(ft), "func")
+			m.field = field
+			methods = append(methods, m)
+		} else {
+			p.checkFlags("interface element of "+t.Name, flags)
+			if p.Module.opts.hidden(flags) {
+				hidden = true
+				continue
+			}
+			list = append(list, field)
+			var e InterfaceElement
+			e.Code = p.exprCode(field.Type)
+			e.URL = p.typeExprURL(field.Pos(), field.Type)
+			e.Terms = p.terms(field.Pos(), field.Type)
+			e.Kind = p.elementKind(e.Terms, field.Type)
+			e.Flags = flags
+			e.doc = cmt
+			e.CommentHtml = p.parseHtmlComment(cmt)
+			elements = append(elements, e)
+		}
+	}
+
+	if !hidden {
+		return methods, elements, decl
+	}
+	// Document a copy of the declaration without the hidden methods and elements.
+	methodList := *it.Methods
+	methodList.List = list
+	it2 := *it
+	it2.Methods = &methodList
+	return methods, elements, declWithType(decl, &it2)
+}
+
+// terms returns the terms of a union, found at pos.
+func (p *Package) terms(pos token.Pos, expr ast.Expr) []Term {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		if e.Op == token.OR {
+			return append(p.terms(pos, e.X), p.terms(pos, e.Y)...)
+		}
+	case *ast.UnaryExpr:
+		if e.Op == token.TILDE {
+			return []Term{{Tilde: true, Type: p.exprCode(e.X), URL: p.typeExprURL(pos, e.X)}}
+		}
+	case *ast.ParenExpr:
+		return p.terms(pos, e.X)
+	}
+	return []Term{{Type: p.exprCode(expr), URL: p.typeExprURL(pos, expr)}}
+}
+
+// elementKind returns the kind of an interface element with the given terms.
+//
+// Whether a single named type is an interface is only known for the types of the package and the predeclared types.
+// Other named types are assumed to be embedded interfaces.
+func (p *Package) elementKind(terms []Term, expr ast.Expr) string {
+	switch {
+	case len(terms) > 1:
+		return ElementUnion
+	case terms[0].Tilde:
+		return ElementApproximation
+	}
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if t, ok := p.types[e.Name]; ok {
+			if t != nil && t.spec != nil {
+				if _, ok := t.spec.Type.(*ast.InterfaceType); !ok {
+					return ElementType
+				}
+			}
+			return ElementEmbedded
+		}
+		if predeclaredTypes[e.Name] && e.Name != "error" && e.Name != "any" && e.Name != "comparable" {
+			return ElementType
+		}
+		return ElementEmbedded
+	case *ast.SelectorExpr:
+		return ElementEmbedded
+	case *ast.InterfaceType:
+		return ElementEmbedded
+	}
+	return ElementType // a type literal, like []byte
+}
+
+// Constraint returns true if the interface type can only be used as a type constraint, because it has
+// elements that are not embedded interfaces.
+func (t *Type) Constraint() bool {
+	for _, e := range t.InterfaceElements {
+		if e.Kind != ElementEmbedded {
+			return true
+		}
+	}
+	return false
+}
+
+// predeclaredTypes are the names of the predeclared types, which are documented in the builtin package.
+var predeclaredTypes = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true, "complex64": true, "complex128": true,
+	"error": true, "float32": true, "float64": true, "int": true, "int8": true, "int16": true, "int32": true,
+	"int64": true, "rune": true, "string": true, "uint": true, "uint8": true, "uint16": true, "uint32": true,
+	"uint64": true, "uintptr": true,
+}
+
+// declWithType returns a copy of the type declaration decl, with its type expression replaced by expr.
+func declWithType(decl *ast.GenDecl, expr ast.Expr) *ast.GenDecl {
+	spec := *decl.Specs[0].(*ast.TypeSpec)
+	spec.Type = expr
+	decl2 := *decl
+	decl2.Specs = []ast.Spec{&spec}
+	return &decl2
+}
+
+// typeExprURL returns the link to the documentation of the named type given by expr, which is found at pos,
+// or the empty string if expr is not a named type or the type is not documented.
+func (p *Package) typeExprURL(pos token.Pos, expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if _, ok := p.types[e.Name]; !ok && predeclaredTypes[e.Name] {
+			return p.Module.opts.externalURL("builtin") + "#" + e.Name
+		}
+		return p.typeURL(e.Name)
+	case *ast.IndexExpr:
+		return p.typeExprURL(pos, e.X) // an instantiated generic type
+	case *ast.IndexListExpr:
+		return p.typeExprURL(pos, e.X)
+	case *ast.SelectorExpr:
+		x, ok := e.X.(*ast.Ident)
+		if !ok {
+			return ""
+		}
+		importPath := p.importPath(pos, x.Name)
+		if importPath == "" {
+			return ""
+		}
+		url, ok := p.Module.set.packageURL(p.Module, importPath)
+		if !ok {
+			url = p.Module.opts.externalURL(importPath)
+		}
+		return url + "#" + e.Sel.Name
+	}
+	return ""
+}
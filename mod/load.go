@@ -0,0 +1,387 @@
+package mod
+
+import (
+	"context"
+	"github.com/goradd/moddoc/internal/jobs"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// moduleSet is the set of modules whose documentation is loaded together.
+//
+// Loading happens in two passes. First the source of every module in the set is parsed, so that the packages
+// that are documented are known. Then the documentation is built, and doc links to packages anywhere in the set
+// point to the local documentation rather than to external documentation.
+type moduleSet struct {
+	opts         *LoadOptions
+	buildContext *build.Context
+	fset         *token.FileSet
+	modules      []*Module
+	checker      *typeChecker // set if the packages are type checked
+}
+
+// parsedDir holds the parsed source of the packages in a directory.
+type parsedDir struct {
+	dirPath string
+	relPath string
+	// names are the names of the packages in the directory, in sorted order.
+	names []string
+	// files are the files of each package, followed by the test files with examples of the package.
+	files map[string][]*ast.File
+}
+
+func newModuleSet(opts *LoadOptions) *moduleSet {
+	return &moduleSet{
+		opts:         opts,
+		buildContext: opts.newBuildContext(),
+		fset:         token.NewFileSet(),
+	}
+}
+
+// addModule reads the go.mod file in modPath, and parses the Go source files of the module.
+//
+// relPath and docDir become the Path and DocDir of the module.
+// The directories below modPath that contain a go.mod file are returned in nested, and are not parsed.
+// If the module has no directories to document, the error wraps [ErrNoPackages], and nested is still returned.
+func (s *moduleSet) addModule(ctx context.Context, modPath string, relPath string, docDir string) (m *Module, nested []string, err error) {
+	opts := s.opts
+	m = new(Module)
+	m.opts = opts
+	m.set = s
+	m.dir = modPath
+	m.Path = relPath
+	m.DocDir = docDir
+	m.IndexFile = opts.IndexFileName()
+	if m.ImportPath, err = getImportPath(modPath); err != nil {
+		return nil, nil, err
+	}
+	m.Name = path.Base(m.ImportPath)
+	m.DirName = filepath.Base(modPath)
+
+	var dirPaths []string
+
+	err = filepath.WalkDir(modPath, func(dirPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return &SourceError{Path: dirPath, Err: err}
+		}
+		if d.IsDir() {
+			relPath, _ := filepath.Rel(modPath, dirPath)
+			relPath = filepath.ToSlash(relPath)
+			if relPath != "." {
+				if opts.skipDir(d.Name(), relPath) {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(dirPath, "go.mod")); err == nil {
+					// A nested module is not part of this module
+					nested = append(nested, dirPath)
+					return filepath.SkipDir
+				}
+			}
+			if opts.includeDir(relPath) {
+				dirPaths = append(dirPaths, dirPath)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(dirPaths) == 0 {
+		return nil, nested, &SourceError{Path: modPath, Err: ErrNoPackages}
+	}
+
+	m.parsed = make([]*parsedDir, len(dirPaths))
+	err = jobs.Run(ctx, len(dirPaths), opts.Jobs, func(i int) (err error) {
+		m.parsed[i], err = s.parseDir(dirPaths[i], modPath)
+		return
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.pkgNames = make(map[string]string)
+	for _, d := range m.parsed {
+		if len(d.names) == 0 {
+			continue
+		}
+		// If there are multiple packages in a directory, links go to the one named after the directory.
+		name := d.names[0]
+		for _, n := range d.names {
+			if n == path.Base(d.relPath) {
+				name = n
+			}
+		}
+		m.pkgNames[d.relPath] = name
+	}
+
+	s.modules = append(s.modules, m)
+	return
+}
+
+// parseDir parses the packages in a single directory.
+//
+// Only the files that match the build context given by the load options are parsed.
+func (s *moduleSet) parseDir(dirPath string, modPath string) (*parsedDir, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, &SourceError{Path: dirPath, Err: err}
+	}
+	relPath, _ := filepath.Rel(modPath, dirPath)
+	d := &parsedDir{
+		dirPath: dirPath,
+		relPath: filepath.ToSlash(relPath),
+		files:   make(map[string][]*ast.File),
+	}
+
+	// A directory may have multiple packages.
+	// Often this is used for test packages.
+	var testFiles []*ast.File
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".go") {
+			continue
+		}
+		if match, err := s.buildContext.MatchFile(dirPath, fileName); err != nil {
+			return nil, &SourceError{Path: filepath.Join(dirPath, fileName), Err: err}
+		} else if !match {
+			continue
+		}
+		f, err := parser.ParseFile(s.fset, filepath.Join(dirPath, fileName), nil, parser.ParseComments)
+		if err != nil {
+			return nil, newParseError(dirPath, err)
+		}
+		if strings.HasSuffix(fileName, "_test.go") {
+			testFiles = append(testFiles, f)
+		} else {
+			d.files[f.Name.Name] = append(d.files[f.Name.Name], f)
+		}
+	}
+
+	// Process the packages in name order so the result is repeatable.
+	for name := range d.files {
+		d.names = append(d.names, name)
+	}
+	sort.Strings(d.names)
+
+	// Test files of the package, and of the external test package, are used to extract the examples.
+	for _, name := range d.names {
+		for _, f := range testFiles {
+			if f.Name.Name == name || f.Name.Name == name+"_test" {
+				d.files[name] = append(d.files[name], f)
+			}
+		}
+	}
+	return d, nil
+}
+
+// build creates the documentation of all the modules in the set.
+func (s *moduleSet) build(ctx context.Context) error {
+	// Type checking needs the syntax trees before go/doc changes them.
+	if s.opts.TypeCheck {
+		s.typeCheck()
+	}
+
+	for _, m := range s.modules {
+		results := make([][]*Package, len(m.parsed))
+		err := jobs.Run(ctx, len(m.parsed), s.opts.Jobs, func(i int) (err error) {
+			results[i], err = m.buildDir(m.parsed[i])
+			return
+		})
+		if err != nil {
+			return err
+		}
+
+		m.Packages = make(map[string]*Package)
+		for _, pkgs := range results {
+			for _, p := range pkgs {
+				m.Packages[p.Path] = p
+				m.PackageList = append(m.PackageList, p)
+				m.Warnings = append(m.Warnings, p.warnings...)
+			}
+		}
+		sort.SliceStable(m.PackageList, func(i, j int) bool {
+			return packagePathLess(m.PackageList[i].Path, m.PackageList[j].Path)
+		})
+		m.setPathParts()
+		m.DeprecatedFile = m.pageFileName("deprecated.html")
+		m.NotesFile = m.pageFileName("notes.html")
+		m.collectDeprecated()
+		m.collectNotes()
+		m.parsed = nil // no longer needed
+	}
+
+	// Identifiers in code can link to any package of the set once all the anchors are known.
+	for _, m := range s.modules {
+		for _, p := range m.PackageList {
+			p.recordAnchors()
+		}
+	}
+
+	// Methods promoted from types in other packages can only be found once all the packages are built.
+	if s.opts.PromotedMethods {
+		for _, m := range s.modules {
+			for _, p := range m.PackageList {
+				p.addInheritedMethods()
+			}
+		}
+	}
+	for _, m := range s.modules {
+		for _, p := range m.PackageList {
+			p.linkCode()
+		}
+	}
+	if s.opts.TypeCheck {
+		s.findImplementations()
+		s.addReferences()
+	}
+	return nil
+}
+
+// findPackage returns the package with the given import path in any module of the set, or nil if there is none.
+func (s *moduleSet) findPackage(importPath string) *Package {
+	for _, m := range s.modules {
+		if p := m.PackageByImportPath(importPath); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// buildDir creates the documentation of the packages in a parsed directory.
+func (m *Module) buildDir(d *parsedDir) (pkgs []*Package, err error) {
+	pkgImportPath := path.Join(m.ImportPath, d.relPath)
+	for _, name := range d.names {
+		files := d.files[name]
+		docPkg, err := doc.NewFromFiles(m.set.fset, files, pkgImportPath, m.opts.docMode())
+		if err != nil {
+			return nil, &SourceError{Path: d.dirPath, Err: err}
+		}
+
+		var srcFiles []*ast.File
+		for _, f := range files {
+			if !strings.HasSuffix(m.set.fset.File(f.Pos()).Name(), "_test.go") {
+				srcFiles = append(srcFiles, f)
+			}
+		}
+		if p := newPackage(docPkg, m.set.fset, d.relPath, m, srcFiles); p != nil {
+			for _, f := range files {
+				p.Files = append(p.Files, m.set.fset.File(f.Pos()).Name())
+			}
+			pkgs = append(pkgs, p)
+		}
+	}
+	return
+}
+
+// pageFileName returns name, or name with "_" added in front as many times as needed so that it is not the name
+// of the index file or of a package page of the module.
+func (m *Module) pageFileName(name string) string {
+	for m.PackageByFileName(name) != nil || name == m.IndexFile {
+		name = "_" + name
+	}
+	return name
+}
+
+// setPathParts builds the PathParts of each package in the module.
+func (m *Module) setPathParts() {
+	for path, pkg := range m.Packages {
+		parts := strings.Split(path, "/")
+		pathParts := []PathPart{
+			{
+				DirName: m.DirName,
+				DocFile: m.IndexFile,
+			},
+		}
+		for i, part := range parts {
+			link := ""
+			pkgPath := strings.Join(parts[:i+1], "/")
+			if p2, ok := m.Packages[pkgPath]; ok {
+				link = p2.FileName
+			}
+			pathParts = append(pathParts, PathPart{
+				DirName: part,
+				DocFile: link,
+			})
+		}
+		pkg.PathParts = pathParts
+	}
+}
+
+// sourcePath returns the path of a source file of the module relative to the directory given to [Load],
+// or the directory of the go.work file, separated by "/".
+func (m *Module) sourcePath(fileName string) string {
+	if rel, err := filepath.Rel(m.dir, fileName); err == nil {
+		return path.Join(m.Path, filepath.ToSlash(rel))
+	}
+	return fileName
+}
+
+// packageURL returns the URL of the documentation of the package with the given import path, relative to the
+// documentation of the module from. If the package is not documented by a module in the set, false is returned.
+func (s *moduleSet) packageURL(from *Module, importPath string) (string, bool) {
+	m, relPath, name, ok := s.lookup(importPath)
+	if !ok {
+		return "", false
+	}
+	return relURL(from.DocDir, m.DocDir) + makeFileName(m.Name, relPath, name), true
+}
+
+// packageName returns the name of the package with the given import path,
+// if the package is documented by a module in the set.
+//
+// Unlike the packages of the modules, this is available while the documentation is being built.
+func (s *moduleSet) packageName(importPath string) (string, bool) {
+	_, _, name, ok := s.lookup(importPath)
+	return name, ok
+}
+
+// lookup finds the module in the set that documents the package with the given import path, and returns the path
+// of the package relative to the module and the name of the package.
+func (s *moduleSet) lookup(importPath string) (m *Module, relPath string, name string, ok bool) {
+	for _, m2 := range s.modules {
+		if (importPath == m2.ImportPath || strings.HasPrefix(importPath, m2.ImportPath+"/")) &&
+			(m == nil || len(m2.ImportPath) > len(m.ImportPath)) {
+			m = m2
+		}
+	}
+	if m == nil {
+		return
+	}
+	relPath = strings.TrimPrefix(strings.TrimPrefix(importPath, m.ImportPath), "/")
+	if relPath == "" {
+		relPath = "."
+	}
+	name, ok = m.pkgNames[relPath]
+	return
+}
+
+// relURL returns the relative URL from the directory from to the directory to, ending with a "/" unless it is empty.
+// Both directories are slash separated paths relative to the same root, and "." is the root.
+func relURL(from string, to string) string {
+	split := func(p string) []string {
+		if p == "." || p == "" {
+			return nil
+		}
+		return strings.Split(p, "/")
+	}
+	fromParts, toParts := split(from), split(to)
+	i := 0
+	for i < len(fromParts) && i < len(toParts) && fromParts[i] == toParts[i] {
+		i++
+	}
+	url := strings.Repeat("../", len(fromParts)-i)
+	for _, p := range toParts[i:] {
+		url += p + "/"
+	}
+	return url
+}
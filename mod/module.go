@@ -3,18 +3,23 @@
 // This process complements the [go/doc] package in that it
 //   - Specifically is designed to output HTML, and
 //   - It creates a structure that is easily consumed by Go templates.
+//
+// Call [Load] to process a module directory, or [LoadWorkspace] to process the modules of a go.work file.
 package mod
 
 import (
+	"context"
+	"errors"
+	"go/build"
 	"go/doc"
-	"go/parser"
-	"go/token"
+	"go/scanner"
 	"golang.org/x/mod/modfile"
 	"io/fs"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -22,124 +27,365 @@ import (
 //
 // Module is structured to be easily consumed by Go templates.
 type Module struct {
-	// Name is the module name extracted from the go.mod file.
+	// Name is the last part of the module path extracted from the go.mod file.
 	Name string
+	// ImportPath is the module path extracted from the go.mod file.
+	ImportPath string
 	// DirName is the name of the directory holding the module. This is not always the same, but often is.
 	DirName string
-	// Packages is the documentation for all the packages in the module.
+	// Path is the relative path from the directory given to [Load], or the directory of the go.work file, to the
+	// module directory, separated by "/". It is "." for the module given to Load.
+	Path string
+	// DocDir is the directory that the documentation of the module is written to, relative to the output directory
+	// of the module given to Load, or of the workspace, separated by "/". It is "." for the module given to Load.
+	DocDir string
+	// IndexFile is the name of the file holding the module index.
+	IndexFile string
+	// Packages is the documentation for all the packages in the module, keyed by the Path of the package.
+	Packages map[string]*Package
+	// PackageList is the documentation for all the packages in the module, sorted by Path so that each package
+	// is followed by the packages below it.
 	// The first package in the list represents the package in the same
 	// directory as the go.mod file, if there is a package there.
-	Packages map[string]*Package
+	PackageList []*Package
+	// Deprecated lists the deprecated packages, types, functions, methods, fields, constants and variables of the
+	// module, in the order of PackageList.
+	Deprecated []DeprecatedItem
+	// DeprecatedFile is the name of the file holding the list of deprecated items.
+	DeprecatedFile string
+	// Notes are the notes of all the packages of the module, in the order of PackageList.
+	Notes []Note
+	// NotesFile is the name of the file holding the list of notes.
+	NotesFile string
+	// Warnings are the problems found in the documentation comments that did not prevent the documentation from being generated.
+	Warnings []string
+	// Modules are the modules nested in the directory of this module, if requested by [LoadOptions.NestedModules].
+	Modules []*Module
+	// Parent is the module that this module is nested in, or nil if this is the module given to Load.
+	Parent *Module
+	// Workspace is the workspace that this module is part of, or nil if the module was not loaded by [LoadWorkspace].
+	Workspace *Workspace
+
+	opts     *LoadOptions
+	set      *moduleSet
+	dir      string
+	parsed   []*parsedDir
+	pkgNames map[string]string // the name of the package to link to in each directory
 }
 
-// NewModule walks a module directory, returning a Module structure.
+// LoadOptions controls how [Load] processes a module.
 //
-// The directory dirPath should contain a go.mod file.
-func NewModule(modPath string) *Module {
-	m := new(Module)
-	importPath := getImportPath(modPath)
-	m.Name = path.Base(importPath)
-	m.DirName = filepath.Base(modPath)
-
-	var dirPaths []string
-
-	err := filepath.WalkDir(modPath, func(path string, d fs.DirEntry, err error) error {
-		if d.IsDir() {
-			if d.Name()[0] == '.' {
-				return filepath.SkipDir
-			}
-			if d.Name() == "internal" {
-				return filepath.SkipDir
-			}
-			dirPaths = append(dirPaths, path)
-		}
-		return nil
-	})
-	if err != nil {
-		log.Fatalf("error examining directory: %s", err)
+// A nil *LoadOptions uses the default settings.
+// Directories are filtered before any source is parsed.
+type LoadOptions struct {
+	// Include lists the packages to document as paths relative to the module directory, separated by "/".
+	// The root package is ".". Patterns may use the wildcards of [path.Match], and a pattern ending
+	// in "/..." also matches every package below it.
+	// If Include is empty, all packages are documented.
+	Include []string
+	// Exclude lists the packages to leave out of the documentation, using the same patterns as Include.
+	// Exclude takes precedence over Include.
+	Exclude []string
+	// Internal will document the packages in "internal" directories.
+	Internal bool
+	// Testdata will document the packages in "testdata" directories.
+	Testdata bool
+	// Vendor will document the packages in "vendor" directories.
+	Vendor bool
+	// DotDirs will document the packages in directories whose names start with a ".".
+	DotDirs bool
+	// Unexported will document the unexported declarations of the packages too, as documentation for
+	// the maintainers of the module. The Exported field of each item tells whether it is exported.
+	Unexported bool
+	// PromotedMethods will document the methods that struct types inherit from their embedded types, in
+	// [Type.Inherited], including the methods of embedded types declared in other packages of the module.
+	PromotedMethods bool
+	// NoteMarkers are the markers of the notes to collect, like "BUG", "TODO" or "SECURITY".
+	// A note is a comment like "// BUG(who): text", where who identifies the author of the note.
+	// The default is [DefaultNoteMarkers].
+	NoteMarkers []string
+	// SourceURL is the base URL used to link notes to the source of the module, like
+	// "https://github.com/goradd/moddoc/blob/main/". The path of the file and a line fragment like "#L10" are
+	// appended to it. If empty, notes are not linked.
+	SourceURL string
+	// TypeCheck will type check the packages of the module set with go/types, to find the concrete types that
+	// implement each interface and the references to the exported functions, types and methods, and to link the
+	// identifiers in the CodeHtml of each item to their documentation.
+	// Imported packages from outside the module set are checked from their source. Type errors are reported as warnings.
+	TypeCheck bool
+	// IgnoreHide will document items even if they have a "doc: hide" command in their comment.
+	IgnoreHide bool
+	// IndexFile is the name of the documentation file of the module index, which the package pages link to.
+	// The default is "index.html".
+	IndexFile string
+	// ExternalURL is the base URL of the documentation of packages outside the module. The import path of the
+	// package is appended to it. The default is [ExternalPackageDoc].
+	ExternalURL string
+	// ExternalURLs maps import path prefixes to the base URL of the documentation of the packages that have that prefix,
+	// overriding ExternalURL. The import path of the package is appended to the URL.
+	ExternalURLs map[string]string
+	// GOOS is the target operating system used to select the files that are documented, as given by build
+	// constraints and file names. The default is the GOOS of the go environment.
+	GOOS string
+	// GOARCH is the target architecture used to select the files that are documented.
+	// The default is the GOARCH of the go environment.
+	GOARCH string
+	// Tags are the additional build tags that are satisfied when selecting the files that are documented.
+	Tags []string
+	// NestedModules will document the modules in the directories below the module directory that have their own go.mod file,
+	// as separate modules. Otherwise, those directories are skipped.
+	NestedModules bool
+	// Jobs is the maximum number of directories that will be parsed at the same time.
+	// If zero, the value of [runtime.GOMAXPROCS] is used.
+	Jobs int
+}
+
+// newBuildContext returns the build context that selects the files to document.
+func (o *LoadOptions) newBuildContext() *build.Context {
+	c := build.Default
+	if o.GOOS != "" {
+		c.GOOS = o.GOOS
+	}
+	if o.GOARCH != "" {
+		c.GOARCH = o.GOARCH
 	}
+	c.BuildTags = o.Tags
+	return &c
+}
 
-	if len(dirPaths) == 0 {
-		log.Fatalf("no directories found")
+// docMode returns the mode used to extract the documentation with the go/doc package.
+func (o *LoadOptions) docMode() doc.Mode {
+	var mode doc.Mode
+	if o.Unexported {
+		mode |= doc.AllDecls
 	}
+	if o.PromotedMethods {
+		mode |= doc.AllMethods
+	}
+	return mode
+}
 
-	m.Packages = getPackages(dirPaths, modPath, m)
-	return m
+// IndexFileName returns the name of the index file, which is IndexFile, or "index.html" if IndexFile is empty.
+func (o *LoadOptions) IndexFileName() string {
+	if o.IndexFile != "" {
+		return o.IndexFile
+	}
+	return "index.html"
 }
 
-func getImportPath(modPath string) string {
-	modPath = filepath.Join(modPath, "go.mod")
-	if _, err := os.Stat(modPath); !os.IsNotExist(err) {
-		b, err := os.ReadFile(modPath)
-		if err != nil {
-			log.Fatalf("could not open %s:%s", modPath, err)
+// externalURL returns the url of the documentation of a package outside the module.
+func (o *LoadOptions) externalURL(importPath string) string {
+	var prefix string
+	base := o.ExternalURL
+	if base == "" {
+		base = ExternalPackageDoc
+	}
+	for p, u := range o.ExternalURLs {
+		if (importPath == p || strings.HasPrefix(importPath, strings.TrimSuffix(p, "/")+"/")) && len(p) > len(prefix) {
+			prefix = p
+			base = u
 		}
-		f, err := modfile.Parse("go.mod", b, nil)
-		if err != nil {
-			log.Fatalf("could not parse %s:%s", modPath, err)
+	}
+	return base + importPath
+}
+
+// skipDir returns true if the directory and everything below it should not be documented.
+func (o *LoadOptions) skipDir(name string, relPath string) bool {
+	switch {
+	case name[0] == '.' && !o.DotDirs:
+		return true
+	case name == "internal" && !o.Internal:
+		return true
+	case name == "testdata" && !o.Testdata:
+		return true
+	case name == "vendor" && !o.Vendor:
+		return true
+	}
+	for _, pattern := range o.Exclude {
+		if strings.HasSuffix(pattern, "/...") && matchPattern(pattern, relPath) {
+			return true
 		}
-		return f.Module.Mod.Path
-	} else {
-		log.Fatalf("could not find go.mod file")
 	}
-	return ""
+	return false
 }
 
-func getPackages(dirPaths []string, modPath string, module *Module) (pkgs map[string]*Package) {
-	pkgs = make(map[string]*Package)
+// includeDir returns true if the package in the directory should be documented.
+func (o *LoadOptions) includeDir(relPath string) bool {
+	for _, pattern := range o.Exclude {
+		if matchPattern(pattern, relPath) {
+			return false
+		}
+	}
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, pattern := range o.Include {
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
 
-	for _, dirPath := range dirPaths {
-		fset := token.NewFileSet()
-		parsedPackages, err := parser.ParseDir(fset, dirPath, nil, parser.ParseComments)
-		if err != nil {
-			log.Fatalf("parse error: %s, %s", dirPath, err)
+// matchPattern reports whether the slash separated relPath matches the pattern.
+func matchPattern(pattern string, relPath string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if pattern == "..." {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "/...")
+	if !ok {
+		matched, _ := path.Match(pattern, relPath)
+		return matched
+	}
+	for p := relPath; ; p = path.Dir(p) {
+		if matched, _ := path.Match(prefix, p); matched {
+			return true
+		}
+		if p == "." || p == "/" {
+			return false
 		}
+	}
+}
 
-		// A directory may have multiple packages.
-		// Often this is used for test packages.
-		for _, pkg := range parsedPackages {
-			// Do not deal with unit test files for now.
-			if strings.HasSuffix(pkg.Name, "_test") {
-				continue
-			}
-
-			relPath, _ := filepath.Rel(modPath, dirPath)
-			pkgImportPath := path.Join(module.Name, relPath)
-
-			docPkg := doc.New(pkg, pkgImportPath, 0)
-			if err != nil {
-				log.Fatalf("doc package error: %s, %s", pkg.Name, err)
-			}
-
-			p := NewPackage(docPkg, fset, relPath, module)
-			if p != nil {
-				pkgs[p.Path] = p
-			}
+// hidden returns true if flags indicate the item should not be documented.
+func (o *LoadOptions) hidden(flags map[string]string) bool {
+	if o.IgnoreHide {
+		return false
+	}
+	_, ok := flags[hideCommand]
+	return ok
+}
+
+// Load walks a module directory, returning a Module structure.
+//
+// The directory modPath should contain a go.mod file. If it does not, the returned error will wrap [ErrNoModFile].
+// Problems reading or parsing the module are returned as a [*ModFileError], [*ParseError] or [*SourceError].
+//
+// Directories below modPath that contain their own go.mod file are not part of the module. They are skipped, unless
+// [LoadOptions.NestedModules] is set, in which case they are documented in [Module.Modules]. A nested module that has
+// no packages to document, for example because of [LoadOptions.Include], is left out.
+func Load(ctx context.Context, modPath string, opts *LoadOptions) (*Module, error) {
+	if opts == nil {
+		opts = new(LoadOptions)
+	}
+	modPath, err := filepath.Abs(modPath)
+	if err != nil {
+		return nil, &SourceError{Path: modPath, Err: err}
+	}
+	s := newModuleSet(opts)
+	m, nested, err := s.addModule(ctx, modPath, ".", ".")
+	if err != nil {
+		return nil, err
+	}
+	for opts.NestedModules && len(nested) > 0 {
+		dir := nested[0]
+		nested = nested[1:]
+		relPath, _ := filepath.Rel(modPath, dir)
+		relPath = filepath.ToSlash(relPath)
+		m2, more, err := s.addModule(ctx, dir, relPath, relPath)
+		nested = append(nested, more...)
+		if errors.Is(err, ErrNoPackages) {
+			continue // the options leave nothing to document in the nested module
+		} else if err != nil {
+			return nil, err
 		}
+		m2.Parent = m
+		m.Modules = append(m.Modules, m2)
+	}
+	sort.Slice(m.Modules, func(i, j int) bool {
+		return packagePathLess(m.Modules[i].Path, m.Modules[j].Path)
+	})
+
+	if err = s.build(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// packagePathLess orders package paths with the root package first, and each package followed by the packages below it.
+func packagePathLess(a, b string) bool {
+	if a == "." || b == "." {
+		return a == "." && b != "."
 	}
+	// Since the separator sorts before any other character, a/b sorts before a-b.
+	return strings.ReplaceAll(a, "/", "\x00") < strings.ReplaceAll(b, "/", "\x00")
+}
 
-	// Take another pass through the packages and build each the PathParts
-	for path, pkg := range pkgs {
-		parts := strings.Split(path, string(filepath.Separator))
-		pathParts := []PathPart{
-			{
-				DirName: module.DirName,
-				DocFile: "index.html",
-			},
+// Package returns the package with the given path relative to the module directory, or nil if there is no
+// such package. The path of the root package is ".".
+func (m *Module) Package(path string) *Package {
+	return m.Packages[path]
+}
+
+// PackageByImportPath returns the package with the given import path, or nil if there is no such package in the module.
+func (m *Module) PackageByImportPath(importPath string) *Package {
+	for _, p := range m.PackageList {
+		if p.ImportPath == importPath {
+			return p
 		}
-		for i, part := range parts {
-			link := ""
-			pkgPath := filepath.Join(parts[:i+1]...)
-			if p2, ok := pkgs[pkgPath]; ok {
-				link = p2.FileName
-			}
-			pathParts = append(pathParts, PathPart{
-				DirName: part,
-				DocFile: link,
-			})
+	}
+	return nil
+}
+
+// ModuleURL returns the relative URL from the documentation of m to the index of the module to.
+func (m *Module) ModuleURL(to *Module) string {
+	return relURL(m.DocDir, to.DocDir) + to.IndexFile
+}
+
+// RootURL returns the relative URL from the documentation of m to the output directory of the module given to [Load],
+// or of the workspace, ending in a "/", or the empty string if they are the same. Templates use it to link to shared files like style sheets.
+func (m *Module) RootURL() string {
+	return relURL(m.DocDir, ".")
+}
+
+// PackageByFileName returns the package documented in the given file, or nil if there is no such package.
+func (m *Module) PackageByFileName(fileName string) *Package {
+	for _, p := range m.PackageList {
+		if p.FileName == fileName {
+			return p
 		}
-		pkg.PathParts = pathParts
 	}
+	return nil
+}
 
-	return
+// NewModule walks a module directory, returning a Module structure.
+//
+// The directory dirPath should contain a go.mod file. NewModule exits the program if the module cannot be loaded.
+//
+// Deprecated: Use [Load], which returns an error instead.
+func NewModule(modPath string) *Module {
+	m, err := Load(context.Background(), modPath, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return m
+}
+
+func getImportPath(modPath string) (string, error) {
+	modPath = filepath.Join(modPath, "go.mod")
+	b, err := os.ReadFile(modPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", &ModFileError{Path: modPath, Err: ErrNoModFile}
+	} else if err != nil {
+		return "", &ModFileError{Path: modPath, Err: err}
+	}
+	f, err := modfile.Parse("go.mod", b, nil)
+	if err != nil {
+		return "", &ModFileError{Path: modPath, Err: err}
+	}
+	if f.Module == nil {
+		return "", &ModFileError{Path: modPath, Err: errors.New("missing module directive")}
+	}
+	return f.Module.Mod.Path, nil
+}
+
+// newParseError converts an error returned by the parser into a *ParseError or *SourceError.
+// Only the message of the first error found is kept, as its location is given by the File and Line of the ParseError.
+func newParseError(dirPath string, err error) error {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return &ParseError{File: list[0].Pos.Filename, Line: list[0].Pos.Line, Err: errors.New(list[0].Msg)}
+	}
+	return &SourceError{Path: dirPath, Err: err}
 }
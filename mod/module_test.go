@@ -0,0 +1,1276 @@
+package mod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeModule creates a temporary module from a map of relative file paths to file contents.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad_errors(t *testing.T) {
+	t.Run("no go.mod", func(t *testing.T) {
+		dir := writeModule(t, map[string]string{"a.go": "package a\n"})
+		_, err := Load(context.Background(), dir, nil)
+		if !errors.Is(err, ErrNoModFile) {
+			t.Errorf("Load() error = %v, want ErrNoModFile", err)
+		}
+	})
+	t.Run("bad go.mod", func(t *testing.T) {
+		dir := writeModule(t, map[string]string{"go.mod": "modul example.com/a\n"})
+		_, err := Load(context.Background(), dir, nil)
+		var modErr *ModFileError
+		if !errors.As(err, &modErr) {
+			t.Errorf("Load() error = %v, want *ModFileError", err)
+		}
+	})
+	t.Run("parse error", func(t *testing.T) {
+		dir := writeModule(t, map[string]string{
+			"go.mod": "module example.com/a\n",
+			"a.go":   "package a\n\nfunc A() {\n",
+		})
+		_, err := Load(context.Background(), dir, nil)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("Load() error = %v, want *ParseError", err)
+		}
+		if filepath.Base(parseErr.File) != "a.go" || parseErr.Line != 3 {
+			t.Errorf("Load() error at %s:%d, want a.go:3", parseErr.File, parseErr.Line)
+		}
+		if strings.Count(err.Error(), "a.go") != 1 {
+			t.Errorf("Load() error = %q, want the location given once", err)
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		dir := writeModule(t, map[string]string{
+			"go.mod": "module example.com/a\n",
+			"a.go":   "package a\n",
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Load(ctx, dir, nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Load() error = %v, want context.Canceled", err)
+		}
+	})
+}
+
+func Test_matchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		relPath string
+		want    bool
+	}{
+		{"a", "a", true},
+		{"a", "a/b", false},
+		{"./a", "a", true},
+		{"a/...", "a", true},
+		{"a/...", "a/b/c", true},
+		{"a/...", "ab", false},
+		{"*/b", "a/b", true},
+		{"*/b/...", "a/b/c", true},
+		{"...", "a/b", true},
+		{".", ".", true},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.relPath); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.relPath, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_options(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":              "module example.com/a\n",
+		"a.go":                "// Package a is documented.\npackage a\n",
+		"b/b.go":              "// Package b is documented.\npackage b\n",
+		"b/c/c.go":            "// Package c is documented.\npackage c\n",
+		"internal/i/i.go":     "// Package i is documented.\npackage i\n",
+		"testdata/t/t.go":     "// Package t is documented.\npackage t\n",
+		"hidden/h.go":         "// Package h is hidden.\n//\n// doc: hide\npackage h\n",
+		".git/g/g.go":         "// Package g is documented.\npackage g\n",
+		"vendor/x.org/v/v.go": "// Package v is documented.\npackage v\n",
+	})
+	tests := []struct {
+		name string
+		opts *LoadOptions
+		want []string
+	}{
+		{"default", nil, []string{".", "b", "b/c"}},
+		{"internal", &LoadOptions{Internal: true}, []string{".", "b", "b/c", "internal/i"}},
+		{"testdata", &LoadOptions{Testdata: true}, []string{".", "b", "b/c", "testdata/t"}},
+		{"vendor", &LoadOptions{Vendor: true}, []string{".", "b", "b/c", "vendor/x.org/v"}},
+		{"dot dirs", &LoadOptions{DotDirs: true}, []string{".", ".git/g", "b", "b/c"}},
+		{"ignore hide", &LoadOptions{IgnoreHide: true}, []string{".", "b", "b/c", "hidden"}},
+		{"exclude", &LoadOptions{Exclude: []string{"b"}}, []string{".", "b/c"}},
+		{"exclude tree", &LoadOptions{Exclude: []string{"b/..."}}, []string{"."}},
+		{"include", &LoadOptions{Include: []string{"b/..."}, Exclude: []string{"b/c"}}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Load(context.Background(), dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for p := range m.Packages {
+				got = append(got, filepath.ToSlash(p))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() packages = %v, want %v", got, tt.want)
+			}
+			for p, pkg := range m.Packages {
+				if want := p == "internal/i"; pkg.Internal != want {
+					t.Errorf("package %s Internal = %v, want %v", p, pkg.Internal, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_warnings(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `// Package a is documented.
+package a
+
+// A is moved to a type that does not exist.
+//
+// doc: type=Missing
+func A() {}
+
+// B has a misspelled command.
+//
+// doc: hdie
+const B = 1
+`,
+	})
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		".: unknown command doc: hdie in comment for constant B",
+		".: type Missing not found in comment for function A",
+	}
+	if !reflect.DeepEqual(m.Warnings, want) {
+		t.Errorf("Load() warnings = %q, want %q", m.Warnings, want)
+	}
+}
+
+func Test_packagePathLess(t *testing.T) {
+	paths := []string{"a-b", "b", "a/c", ".", "a", "a/c/d", "a/b"}
+	sort.Slice(paths, func(i, j int) bool {
+		return packagePathLess(paths[i], paths[j])
+	})
+	want := []string{".", "a", "a/b", "a/c", "a/c/d", "a-b", "b"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("sorted paths = %v, want %v", paths, want)
+	}
+}
+
+func TestLoad_buildConstraints(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":         "module example.com/a\n",
+		"a.go":           "// Package a is documented.\npackage a\n",
+		"a_linux.go":     "package a\n\n// Linux is only on linux.\nfunc Linux() {}\n",
+		"a_windows.go":   "package a\n\n// Windows is only on windows.\nfunc Windows() {}\n",
+		"tagged.go":      "//go:build extra\n\npackage a\n\n// Extra needs a tag.\nfunc Extra() {}\n",
+		"gen.go":         "//go:build ignore\n\npackage main\n\nfunc main() {}\n",
+		"a_test.go":      "package a\n\nfunc TestA() {}\n",
+		"b/b_windows.go": "// Package b is only on windows.\npackage b\n\n// B is exported.\nfunc B() {}\n",
+	})
+	tests := []struct {
+		name      string
+		opts      *LoadOptions
+		wantFuncs []string
+		wantB     bool
+	}{
+		{"linux", &LoadOptions{GOOS: "linux"}, []string{"Linux"}, false},
+		{"windows", &LoadOptions{GOOS: "windows", Tags: []string{"extra"}}, []string{"Extra", "Windows"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Load(context.Background(), dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range m.Package(".").Functions {
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.wantFuncs) {
+				t.Errorf("Load() functions = %v, want %v", got, tt.wantFuncs)
+			}
+			if gotB := m.Package("b") != nil; gotB != tt.wantB {
+				t.Errorf("Load() has package b = %v, want %v", gotB, tt.wantB)
+			}
+		})
+	}
+}
+
+func TestLoad_examples(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `// Package a is documented.
+package a
+
+// T is a type.
+type T int
+
+// M is a method.
+func (T) M() {}
+
+// F is a function.
+func F() int { return 1 }
+`,
+		"a_test.go": `package a
+
+func ExampleF() {
+	x := F()
+	println(x)
+	// Output: 1
+}
+`,
+		"example_test.go": `package a_test
+
+import "fmt"
+
+// This example shows the whole package.
+func Example() {
+	fmt.Println("a")
+}
+
+func ExampleT_M_second() {
+	fmt.Println("b")
+	fmt.Println("c")
+	// Unordered output:
+	// c
+	// b
+}
+`,
+	})
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := m.Package(".")
+	if len(p.Examples) != 1 || p.Examples[0].Code != "fmt.Println(\"a\")\n" {
+		t.Errorf("package examples = %#v", p.Examples)
+	}
+	f := p.Functions[0].Examples
+	if len(f) != 1 || f[0].Code != "x := F()\nprintln(x)\n" || f[0].Output != "1\n" {
+		t.Errorf("function examples = %#v", f)
+	}
+	e := p.Types[0].Methods[0].Examples
+	if len(e) != 1 || e[0].Suffix != "second" || !e[0].Unordered || e[0].Output != "c\nb\n" {
+		t.Errorf("method examples = %#v", e)
+	}
+}
+
+func TestLoad_nestedModules(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":         "module example.com/a\n",
+		"a.go":           "// Package a uses [example.com/a/sub/b.B].\npackage a\n",
+		"sub/go.mod":     "module example.com/a/sub\n",
+		"sub/s.go":       "package sub\n",
+		"sub/b/b.go":     "package b\n\n// B is a function.\nfunc B() {}\n",
+		"sub/c/go.mod":   "module example.com/c\n",
+		"sub/c/c.go":     "package c\n",
+		"other/other.go": "package other\n\nfunc O() {}\n",
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pathsOf(m.PackageList), []string{".", "other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("packages = %v, want %v", got, want)
+	}
+
+	m, err = Load(context.Background(), dir, &LoadOptions{NestedModules: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var modPaths []string
+	for _, m2 := range m.Modules {
+		modPaths = append(modPaths, m2.Path)
+		if m2.Parent != m {
+			t.Errorf("module %s has the wrong parent", m2.Path)
+		}
+	}
+	if want := []string{"sub", "sub/c"}; !reflect.DeepEqual(modPaths, want) {
+		t.Fatalf("modules = %v, want %v", modPaths, want)
+	}
+	sub := m.Modules[0]
+	if p := sub.Package("b"); p == nil || p.ImportPath != "example.com/a/sub/b" {
+		t.Errorf("sub module package b = %v, want import path example.com/a/sub/b", p)
+	}
+	if got, want := m.ModuleURL(sub), "sub/index.html"; got != want {
+		t.Errorf("ModuleURL() = %q, want %q", got, want)
+	}
+	if got, want := sub.RootURL(), "../"; got != want {
+		t.Errorf("RootURL() = %q, want %q", got, want)
+	}
+	if html := string(m.Package(".").CommentHtml); !strings.Contains(html, `href="sub/b.html#B"`) {
+		t.Errorf("link to nested module not resolved locally: %s", html)
+	}
+}
+
+func TestLoad_nestedModulesInclude(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":       "module example.com/a\n",
+		"a.go":         "package a\n",
+		"sub/s.go":     "package sub\n\n// S is a function.\nfunc S() {}\n",
+		"n/go.mod":     "module example.com/n\n",
+		"n/n.go":       "package n\n",
+		"n/m/go.mod":   "module example.com/m\n",
+		"n/m/sub/s.go": "package sub\n\n// S is a function.\nfunc S() {}\n",
+		"empty/go.mod": "module example.com/empty\n",
+		"empty/e.go":   "package e\n",
+	})
+
+	m, err := Load(context.Background(), dir, &LoadOptions{NestedModules: true, Include: []string{"sub"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var modPaths []string
+	for _, m2 := range m.Modules {
+		modPaths = append(modPaths, m2.Path)
+	}
+	// The modules with no package named sub are left out, but not the modules nested in them.
+	if want := []string{"n/m"}; !reflect.DeepEqual(modPaths, want) {
+		t.Errorf("modules = %v, want %v", modPaths, want)
+	}
+
+	_, err = Load(context.Background(), filepath.Join(dir, "n"), &LoadOptions{Include: []string{"sub"}})
+	var srcErr *SourceError
+	if !errors.Is(err, ErrNoPackages) || !errors.As(err, &srcErr) || srcErr.Path != filepath.Join(dir, "n") {
+		t.Errorf("Load() error = %v, want ErrNoPackages for %s", err, filepath.Join(dir, "n"))
+	}
+}
+
+func pathsOf(pkgs []*Package) (paths []string) {
+	for _, p := range pkgs {
+		paths = append(paths, p.Path)
+	}
+	return
+}
+
+func TestLoadWorkspace(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.work":  "go 1.20\n\nuse (\n\t.\n\t./b\n)\n",
+		"go.mod":   "module example.com/a\n",
+		"a.go":     "// Package a uses [example.com/b/x.X].\npackage a\n",
+		"b/go.mod": "module example.com/b\n",
+		"b/x/x.go": "package x\n\n// X is a function.\nfunc X() {}\n",
+	})
+
+	ws, err := LoadWorkspace(context.Background(), filepath.Join(dir, "go.work"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docDirs []string
+	for _, m := range ws.Modules {
+		docDirs = append(docDirs, m.DocDir)
+		if m.Workspace != ws {
+			t.Errorf("module %s has the wrong workspace", m.Path)
+		}
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(docDirs, want) {
+		t.Fatalf("module doc dirs = %v, want %v", docDirs, want)
+	}
+	if len(ws.Modules[0].PackageList) != 1 {
+		t.Errorf("the module in the workspace directory documents the packages of the other module")
+	}
+	if html := string(ws.Modules[0].Package(".").CommentHtml); !strings.Contains(html, `href="../b/x.html#X"`) {
+		t.Errorf("link to workspace module not resolved locally: %s", html)
+	}
+
+	_, err = LoadWorkspace(context.Background(), filepath.Join(dir, "b"), nil)
+	if !errors.Is(err, ErrNoWorkFile) {
+		t.Errorf("LoadWorkspace() error = %v, want ErrNoWorkFile", err)
+	}
+
+	// Module a has no package x, so it is left out.
+	ws, err = LoadWorkspace(context.Background(), filepath.Join(dir, "go.work"), &LoadOptions{Include: []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ws.Modules) != 1 || ws.Modules[0].Path != "b" {
+		t.Errorf("modules = %v, want only b", ws.Modules)
+	}
+	_, err = LoadWorkspace(context.Background(), filepath.Join(dir, "go.work"), &LoadOptions{Include: []string{"y"}})
+	var srcErr *SourceError
+	if !errors.Is(err, ErrNoPackages) || !errors.As(err, &srcErr) || srcErr.Path != filepath.Join(dir, "go.work") {
+		t.Errorf("LoadWorkspace() error = %v, want ErrNoPackages for the go.work file", err)
+	}
+}
+
+func TestLoad_unexported(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `package a
+
+const c = 1
+
+// T is a type.
+type T struct{ f int }
+
+func (T) m() {}
+
+// F is a function.
+func F() {}
+
+func f() {}
+`,
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := m.Package(".")
+	if len(p.Functions) != 1 || len(p.Constants) != 0 || len(p.Types[0].Methods) != 0 {
+		t.Errorf("unexported declarations documented by default")
+	}
+
+	m, err = Load(context.Background(), dir, &LoadOptions{Unexported: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = m.Package(".")
+	var funcs []string
+	for _, f := range p.Functions {
+		funcs = append(funcs, fmt.Sprintf("%s %v", f.Name, f.Exported))
+	}
+	if want := []string{"F true", "f false"}; !reflect.DeepEqual(funcs, want) {
+		t.Errorf("functions = %v, want %v", funcs, want)
+	}
+	if len(p.Constants) != 1 || p.Constants[0].Exported {
+		t.Errorf("constants = %v, want one unexported constant", p.Constants)
+	}
+	if len(p.Types[0].Methods) != 1 || p.Types[0].Methods[0].Exported || !p.Types[0].Exported {
+		t.Errorf("type T = %+v, want exported type with an unexported method", p.Types[0])
+	}
+	if !strings.Contains(p.Types[0].Code, "f int") {
+		t.Errorf("unexported field missing from code: %s", p.Types[0].Code)
+	}
+}
+
+func TestLoad_promotedMethods(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `package a
+
+import "example.com/a/b"
+
+// Base is embedded.
+type Base struct{}
+
+// M is promoted.
+func (*Base) M() {}
+
+// N is shadowed.
+func (Base) N() {}
+
+// Doer is an interface.
+type Doer interface {
+	// Do does something.
+	Do()
+}
+
+// Hidden is embedded without documentation.
+type Hidden interface{ H() }
+
+// T embeds types.
+type T struct {
+	*Base
+	Doer
+	b.Other
+	Hidden // doc: hide
+}
+
+// N is declared on T.
+func (T) N() {}
+`,
+		"b/b.go": "package b\n\n// Other is in another package.\ntype Other struct{}\n\n// O is promoted.\nfunc (Other) O() {}\n",
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := m.Package(".").types["T"]; len(typ.Inherited) != 0 {
+		t.Errorf("promoted methods documented by default: %v", typ.Inherited)
+	}
+
+	m, err = Load(context.Background(), dir, &LoadOptions{PromotedMethods: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ := m.Package(".").types["T"]
+	var got []string
+	for _, g := range typ.Inherited {
+		for _, method := range g.Methods {
+			got = append(got, g.From+" "+method.Name+" "+g.MethodURL(method))
+		}
+	}
+	sort.Strings(got)
+	want := []string{"*Base M #Base.M", "Doer Do #Doer.Do", "b.Other O b.html#Other.O"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inherited methods = %v, want %v", got, want)
+	}
+	if len(typ.Methods) != 1 || typ.Methods[0].Name != "N" {
+		t.Errorf("methods = %v, want N", typ.Methods)
+	}
+}
+
+func TestLoad_promotedMethodsNested(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `package a
+
+import "example.com/a/other"
+
+// A embeds a type that embeds other types.
+type A struct {
+	other.B
+}
+
+// S embeds other.G, whose M shadows the M of C, which is deeper.
+type S struct {
+	other.G
+	Local
+}
+
+// Local embeds C.
+type Local struct {
+	C
+}
+
+// C is embedded in Local.
+type C struct{}
+
+// M is shadowed in S.
+func (C) M() {}
+
+// U embeds other.G and D, which both have an M at the same depth.
+type U struct {
+	other.G
+	D
+}
+
+// D is embedded in U.
+type D struct{}
+
+// M is ambiguous in U.
+func (D) M() {}
+`,
+		"other/other.go": `package other
+
+// B embeds C and D.
+type B struct {
+	C
+	*D
+}
+
+// BM is declared on B.
+func (B) BM() {}
+
+// C is embedded in B.
+type C struct{}
+
+// CM is declared on C.
+func (C) CM() {}
+
+// BM is shadowed by B.BM.
+func (C) BM() {}
+
+// D is embedded in B.
+type D struct {
+	E
+}
+
+// E is an interface that embeds another.
+type E interface {
+	F
+}
+
+// F is embedded in E.
+type F interface {
+	// FM is declared on F.
+	FM()
+}
+
+// G is embedded in a.S and a.U.
+type G struct{}
+
+// M is declared on G.
+func (G) M() {}
+`,
+	})
+
+	m, err := Load(context.Background(), dir, &LoadOptions{PromotedMethods: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		typeName string
+		want     []string
+	}{
+		{"A", []string{
+			"other.B BM 1 other.html#B.BM",
+			"other.C CM 2 other.html#C.CM",
+			"other.F FM 4 other.html#F.FM",
+		}},
+		{"S", []string{"other.G M 1 other.html#G.M"}},
+		{"U", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, g := range m.Package(".").types[tt.typeName].Inherited {
+			for _, method := range g.Methods {
+				got = append(got, fmt.Sprintf("%s %s %d %s", g.From, method.Name, method.Level, g.MethodURL(method)))
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("inherited methods of %s = %v, want %v", tt.typeName, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_fields(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": "package a\n\nimport \"io\"\n\n" +
+			"// T has fields. See [T.X].\n" +
+			"type T struct {\n" +
+			"\t// X and Y are coordinates.\n" +
+			"\tX, Y int `json:\"x\"`\n" +
+			"\tio.Reader // the source\n" +
+			"\t// Secret is hidden.\n" +
+			"\t//\n" +
+			"\t// doc: hide\n" +
+			"\tSecret string\n" +
+			"\t// W is hidden by its line comment.\n" +
+			"\tW int // doc: hide\n" +
+			"\tz int\n" +
+			"}\n",
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ := m.Package(".").Types[0]
+	var got []string
+	for _, f := range typ.Fields {
+		got = append(got, fmt.Sprintf("%s %s %q %v %s", f.Name, f.Type, f.Tag, f.Embedded, strings.TrimSpace(f.CommentHtml)))
+	}
+	want := []string{
+		`X int "json:\"x\"" false <p>X and Y are coordinates.`,
+		`Y int "json:\"x\"" false <p>X and Y are coordinates.`,
+		`Reader io.Reader "" true <p>the source`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %q, want %q", got, want)
+	}
+	if strings.Contains(typ.Code, "Secret") || strings.Contains(typ.Code, "W int") {
+		t.Errorf("hidden field in code: %s", typ.Code)
+	}
+	if !strings.Contains(typ.CommentHtml, `href="#T.X"`) {
+		t.Errorf("link to field not resolved: %s", typ.CommentHtml)
+	}
+}
+
+func TestLoad_interface(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `package a
+
+import "io"
+
+// I is an interface.
+type I interface {
+	io.Reader
+	Other
+	// M does something.
+	M(x int) (string, error)
+	// H is hidden.
+	//
+	// doc: hide
+	H()
+	// X is hidden by its line comment.
+	X() // doc: hide
+}
+
+// Other is declared after I.
+type Other interface{ O() }
+`,
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ := m.Package(".").types["I"]
+	var got []string
+	for _, e := range typ.InterfaceElements {
+		got = append(got, e.Code+" "+e.URL)
+	}
+	for _, method := range typ.InterfaceMethods {
+		got = append(got, method.Code+" "+strings.TrimSpace(method.CommentHtml))
+	}
+	want := []string{
+		"io.Reader " + ExternalPackageDoc + "io#Reader",
+		"Other #Other",
+		"M(x int) (string, error) <p>M does something.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("interface = %q, want %q", got, want)
+	}
+	if strings.Contains(typ.Code, "H()") || strings.Contains(typ.Code, "X()") {
+		t.Errorf("hidden method in code: %s", typ.Code)
+	}
+}
+
+func TestLoad_signatures(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `package a
+
+// F is generic.
+func F[T any, U comparable](x, y T, rest ...U) (n int, err error) { return }
+
+// G has one result.
+func G(int) error { return nil }
+
+// T is a type.
+type T struct{}
+
+// M has a pointer receiver.
+func (t *T) M(s string) {}
+`,
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := m.Package(".")
+	f := p.Functions[0].Signature
+	if !f.Variadic || len(f.TypeParams) != 2 || f.TypeParams[1] != (Param{Name: "U", Type: "comparable", URL: ExternalPackageDoc + "builtin#comparable"}) || f.Receiver != nil {
+		t.Errorf("F signature = %+v", f)
+	}
+	if got, want := f.String(), "[T any, U comparable](x T, y T, rest ...U) (n int, err error)"; got != want {
+		t.Errorf("F signature = %q, want %q", got, want)
+	}
+	if got, want := p.Functions[1].Signature.String(), "(int) error"; got != want {
+		t.Errorf("G signature = %q, want %q", got, want)
+	}
+	if got, want := p.Functions[1].Code, "func G(int) error"; strings.TrimSpace(got) != want {
+		t.Errorf("G code = %q, want %q", got, want)
+	}
+	method := p.types["T"].Methods[0].Signature
+	if method.Receiver == nil || *method.Receiver != (Param{Name: "t", Type: "*T"}) || !method.PointerReceiver {
+		t.Errorf("M signature = %+v", method)
+	}
+}
+
+func TestLoad_generics(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `package a
+
+import "fmt"
+
+// Number is a constraint.
+type Number interface {
+	~int | ~float64
+}
+
+// Named is a constraint with a single type.
+type Named interface {
+	int
+	fmt.Stringer
+}
+
+// List is generic.
+type List[T Number, S fmt.Stringer] []T
+`,
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := m.Package(".")
+	number := p.types["Number"]
+	if !number.Constraint() || number.InterfaceElements[0].Kind != ElementUnion {
+		t.Errorf("Number is not a union constraint: %+v", number.InterfaceElements)
+	}
+	if got, want := number.InterfaceElements[0].Terms, []Term{
+		{Tilde: true, Type: "int", URL: ExternalPackageDoc + "builtin#int"},
+		{Tilde: true, Type: "float64", URL: ExternalPackageDoc + "builtin#float64"},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Number terms = %+v, want %+v", got, want)
+	}
+	var kinds []string
+	for _, e := range p.types["Named"].InterfaceElements {
+		kinds = append(kinds, e.Kind)
+	}
+	if want := []string{ElementType, ElementEmbedded}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Named element kinds = %v, want %v", kinds, want)
+	}
+	want := []Param{
+		{Name: "T", Type: "Number", URL: "#Number"},
+		{Name: "S", Type: "fmt.Stringer", URL: ExternalPackageDoc + "fmt#Stringer"},
+	}
+	if got := p.types["List"].TypeParams; !reflect.DeepEqual(got, want) {
+		t.Errorf("List type params = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoad_typeKinds(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `package a
+
+import "time"
+
+type (
+	Alias    = map[string]int
+	Weekday  int
+	Map      map[string]bool
+	Pointer  *int
+	Slice    []byte
+	Array    [4]byte
+	Chan     chan int
+	Func     func()
+	Duration time.Duration
+	Set[T comparable] map[T]struct{}
+	Struct struct{}
+)
+`,
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, typ := range m.Package(".").Types {
+		got = append(got, fmt.Sprintf("%s %s %s %v", typ.Name, typ.Kind, typ.Underlying, typ.Generic))
+	}
+	want := []string{
+		"Alias alias map[string]int false",
+		"Array array [4]byte false",
+		"Chan chan chan int false",
+		"Duration named time.Duration false",
+		"Func func func() false",
+		"Map map map[string]bool false",
+		"Pointer pointer *int false",
+		"Set map map[T]struct{} true",
+		"Slice slice []byte false",
+		"Struct struct struct{} false",
+		"Weekday basic int false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("types = %q, want %q", got, want)
+	}
+}
+
+func TestLoad_deprecated(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `// Package a does things.
+package a
+
+// Old is the old constant.
+//
+// Deprecated: use
+// New instead.
+const Old = 1
+
+const New = 2
+
+// T is a type.
+type T struct {
+	// F is a field.
+	//
+	// Deprecated: use G.
+	F int
+	G int
+}
+
+// M does nothing.
+//
+// Deprecated: Not needed anymore.
+func (T) M() {}
+
+// Deprecated is not a deprecation notice.
+func Deprecated() {}
+`,
+		"old/old.go": `// Package old is old.
+//
+// Deprecated: use package a.
+package old
+
+func F() {}
+`,
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range m.Deprecated {
+		got = append(got, fmt.Sprintf("%s %s %s: %s", d.Kind, d.Name, d.URL, d.Message))
+	}
+	want := []string{
+		"const Old a.html#Old: use New instead.",
+		"field T.F a.html#T.F: use G.",
+		"method T.M a.html#T.M: Not needed anymore.",
+		"package example.com/a/old old.html: use package a.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deprecated = %q, want %q", got, want)
+	}
+	if m.DeprecatedFile != "deprecated.html" {
+		t.Errorf("DeprecatedFile = %q", m.DeprecatedFile)
+	}
+}
+
+func TestLoad_notes(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `// Package a does things.
+package a
+
+// BUG(ann): F panics
+// on nil.
+
+// TODO(bob): Make F faster.
+
+// F does things.
+func F() {}
+`,
+	})
+
+	tests := []struct {
+		name string
+		opts *LoadOptions
+		want []string
+	}{
+		{"default", nil, []string{"BUG ann a.go:4 : F panics on nil."}},
+		{"markers", &LoadOptions{NoteMarkers: []string{"TODO", "BUG"}, SourceURL: "https://src/"}, []string{
+			"TODO bob a.go:7 https://src/a.go#L7: Make F faster.",
+			"BUG ann a.go:4 https://src/a.go#L4: F panics on nil.",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Load(context.Background(), dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, n := range m.Notes {
+				got = append(got, fmt.Sprintf("%s %s %s:%d %s: %s", n.Marker, n.UID, n.File, n.Line, n.URL, strings.Join(strings.Fields(n.Body), " ")))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notes = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(m.Package(".").Notes, m.Notes) {
+				t.Error("package notes differ from module notes")
+			}
+		})
+	}
+}
+
+func TestLoad_implementations(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `// Package a does things.
+package a
+
+import "fmt"
+
+// Shape has an area.
+type Shape interface {
+	Area() float64
+}
+
+// Empty is implemented by everything, and is left out.
+type Empty interface{}
+
+// Square is a shape.
+type Square struct{ side float64 }
+
+func (s Square) Area() float64 { return s.side * s.side }
+
+// String uses fmt.
+func (s Square) String() string { return fmt.Sprint(s.side) }
+`,
+		"b/b.go": `// Package b has more shapes.
+package b
+
+import "example.com/a"
+
+// Circle is a shape if used as a pointer.
+type Circle struct{ r float64 }
+
+func (c *Circle) Area() float64 { return c.r }
+
+// Shapes are shapes.
+type Shapes []a.Shape
+`,
+	})
+
+	m, err := Load(context.Background(), dir, &LoadOptions{TypeCheck: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Warnings) > 0 {
+		t.Errorf("warnings: %q", m.Warnings)
+	}
+	list := func(impls []Implementation) (s []string) {
+		for _, i := range impls {
+			s = append(s, fmt.Sprintf("%s %s %v", i.Name, i.URL, i.Pointer))
+		}
+		return
+	}
+	a, b := m.Package("."), m.Package("b")
+	if got, want := list(a.Types[1].Implementations), []string{"Square #Square false", "b.Circle b.html#Circle true"}; a.Types[1].Name != "Shape" || !reflect.DeepEqual(got, want) {
+		t.Errorf("%s implementations = %q, want %q", a.Types[1].Name, got, want)
+	}
+	if got := list(a.Types[0].Implementations); got != nil {
+		t.Errorf("%s implementations = %q, want none", a.Types[0].Name, got)
+	}
+	if got, want := list(b.Types[0].Implements), []string{"a.Shape a.html#Shape true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Circle implements %q, want %q", got, want)
+	}
+	if got := list(b.Types[1].Implements); got != nil {
+		t.Errorf("Shapes implements %q, want none", got)
+	}
+}
+
+func TestLoad_typeCheckOtherDir(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `// Package a uses an internal package.
+package a
+
+import "example.com/a/internal/x"
+
+// F returns an internal value.
+func F() x.T { return x.T{} }
+`,
+		"internal/x/x.go": "package x\n\ntype T struct{}\n",
+	})
+
+	// The packages that are not documented are found from the module directory, not the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	m, err := Load(context.Background(), dir, &LoadOptions{TypeCheck: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Warnings) > 0 {
+		t.Errorf("warnings: %q", m.Warnings)
+	}
+}
+
+func TestLoad_codeHtml(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `// Package a does things.
+package a
+
+import "io"
+
+// T is a type.
+type T struct{}
+
+// New makes a T.
+func New() *T { return nil }
+
+// Read reads.
+func (t *T) Read(r io.Reader, ch <-chan int) {}
+`,
+		"b/b.go": `// Package b uses a.
+package b
+
+import "example.com/a"
+
+// V is made by a.New.
+var V = a.New()
+`,
+	})
+
+	m, err := Load(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Package(".").Types[0].Methods[0].CodeHtml, "func (t *T) Read(r io.Reader, ch &lt;-chan int)\n"; got != want {
+		t.Errorf("CodeHtml = %q, want %q", got, want)
+	}
+
+	m, err = Load(context.Background(), dir, &LoadOptions{TypeCheck: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `func (t *<a href="#T">T</a>) Read(r <a href="https://pkg.go.dev/io">io</a>.<a href="https://pkg.go.dev/io#Reader">Reader</a>, ch &lt;-chan <a href="https://pkg.go.dev/builtin#int">int</a>)
+`
+	if got := m.Package(".").Types[0].Methods[0].CodeHtml; got != want {
+		t.Errorf("CodeHtml = %q, want %q", got, want)
+	}
+	want = `var V = <a href="a.html">a</a>.<a href="a.html#T.New">New</a>()
+`
+	if got := m.Package("b").Variables[0].CodeHtml; got != want {
+		t.Errorf("CodeHtml = %q, want %q", got, want)
+	}
+}
+
+func TestLoad_codeHtmlInherited(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"other/other.go": `// Package other has types to embed.
+package other
+
+import "io"
+
+// Helper helps.
+type Helper int
+
+// Other is embedded.
+type Other struct{}
+
+// O returns a Helper.
+func (Other) O() Helper { return 0 }
+
+// I is an interface.
+type I interface {
+	// R reads.
+	R(r io.Reader) Helper
+}
+`,
+		"m/m.go": `// Package m embeds other.Other.
+package m
+
+import "example.com/a/other"
+
+// T embeds other.Other.
+type T struct {
+	other.Other
+}
+`,
+	})
+
+	m, err := Load(context.Background(), dir, &LoadOptions{TypeCheck: true, PromotedMethods: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inherited := m.Package("m").Types[0].Inherited
+	if len(inherited) != 1 || len(inherited[0].Methods) != 1 {
+		t.Fatalf("Inherited = %v, want the method O", inherited)
+	}
+	want := `func (<a href="other.html#Other">Other</a>) O() <a href="other.html#Helper">Helper</a>
+`
+	if got := inherited[0].Methods[0].CodeHtml; got != want {
+		t.Errorf("CodeHtml = %q, want %q", got, want)
+	}
+	want = `R(r <a href="https://pkg.go.dev/io">io</a>.<a href="https://pkg.go.dev/io#Reader">Reader</a>) <a href="#Helper">Helper</a>`
+	if got := m.Package("other").Types[1].InterfaceMethods[0].CodeHtml; got != want {
+		t.Errorf("CodeHtml = %q, want %q", got, want)
+	}
+}
+
+func TestLoad_references(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go": `// Package a does things.
+package a
+
+// T is a type.
+type T struct {
+	next *T
+}
+
+// New makes a T.
+func New() *T {
+	return New()
+}
+
+// M does things.
+func (t *T) M() {}
+`,
+		"b/b.go": `// Package b uses a.
+package b
+
+import "example.com/a"
+
+// F uses a.
+func F() {
+	t := a.New(); t.M()
+	t.M()
+}
+`,
+	})
+
+	m, err := Load(context.Background(), dir, &LoadOptions{TypeCheck: true, SourceURL: "https://src/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := func(refs []Reference) (s []string) {
+		for _, r := range refs {
+			s = append(s, fmt.Sprintf("%s %s %s %s:%d %s", r.ImportPath, r.Decl, r.DeclURL, r.File, r.Line, r.URL))
+		}
+		return
+	}
+	typ := m.Package(".").Types[0]
+	if got, want := list(typ.References), []string{"example.com/a New #T.New a.go:10 https://src/a.go#L10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("T references = %q, want %q", got, want)
+	}
+	if got, want := list(typ.Functions[0].References), []string{"example.com/a/b F b.html#F b/b.go:8 https://src/b/b.go#L8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("New references = %q, want %q", got, want)
+	}
+	want := []string{
+		"example.com/a/b F b.html#F b/b.go:8 https://src/b/b.go#L8",
+		"example.com/a/b F b.html#F b/b.go:9 https://src/b/b.go#L9",
+	}
+	if got := list(typ.Methods[0].References); !reflect.DeepEqual(got, want) {
+		t.Errorf("M references = %q, want %q", got, want)
+	}
+}
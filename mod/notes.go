@@ -0,0 +1,74 @@
+package mod
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultNoteMarkers are the note markers collected when [LoadOptions.NoteMarkers] is empty.
+var DefaultNoteMarkers = []string{"BUG"}
+
+// Note is a marked comment, like "// BUG(who): text", found in the source of a package.
+//
+// Notes are collected for the markers listed in [LoadOptions.NoteMarkers].
+type Note struct {
+	// Package is the package whose source holds the note.
+	Package *Package
+	// Marker is the marker of the note, like "BUG" or "TODO".
+	Marker string
+	// UID is the user id or other identifier given in parentheses after the marker, usually the author of the note.
+	UID string
+	// Body is the text of the note.
+	Body string
+	// File is the path of the source file holding the note, relative to the directory given to [Load], or
+	// the directory of the go.work file, separated by "/".
+	File string
+	// Line is the line number of the note in the file.
+	Line int
+	// URL is the link to the note in the source of the module, or the empty string if
+	// [LoadOptions.SourceURL] is not set.
+	URL string
+}
+
+// noteMarkers returns the markers of the notes to collect.
+func (o *LoadOptions) noteMarkers() []string {
+	if len(o.NoteMarkers) > 0 {
+		return o.NoteMarkers
+	}
+	return DefaultNoteMarkers
+}
+
+// sourceURL returns the link to a line of a source file, given by its path relative to the directory given to Load.
+func (o *LoadOptions) sourceURL(file string, line int) string {
+	if o.SourceURL == "" {
+		return ""
+	}
+	return o.SourceURL + file + "#L" + strconv.Itoa(line)
+}
+
+// parseNotes collects the notes that go/doc found in the package, for each of the markers, in the order of the markers.
+func (p *Package) parseNotes() {
+	for _, marker := range p.Module.opts.noteMarkers() {
+		for _, n := range p.DocPkg.Notes[marker] {
+			pos := p.Fset.Position(n.Pos)
+			file := p.Module.sourcePath(pos.Filename)
+			p.Notes = append(p.Notes, Note{
+				Package: p,
+				Marker:  marker,
+				UID:     n.UID,
+				Body:    strings.TrimSpace(n.Body),
+				File:    file,
+				Line:    pos.Line,
+				URL:     p.Module.opts.sourceURL(file, pos.Line),
+			})
+		}
+	}
+}
+
+// collectNotes lists the notes of all the packages of the module, in the order of the package list.
+func (m *Module) collectNotes() {
+	m.Notes = nil
+	for _, p := range m.PackageList {
+		m.Notes = append(m.Notes, p.Notes...)
+	}
+}
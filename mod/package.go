@@ -2,15 +2,15 @@ package mod
 
 import (
 	"bytes"
+	"fmt"
 	"go/ast"
 	"go/doc"
 	"go/doc/comment"
 	"go/format"
 	"go/token"
-	"log"
-	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -24,6 +24,11 @@ type HTMLer interface {
 	toHTML(pkg *Package) string
 }
 
+// commenter is an item with a doc comment.
+type commenter interface {
+	comment() string
+}
+
 // PathPart is a directory in a directory list that refers to a package.
 type PathPart struct {
 	// DirName is the name of the directory
@@ -53,36 +58,90 @@ type Package struct {
 
 	// PathParts are the names of the directories leading from the home directory to the package.
 	// The first directory is the name of the directory holding the module.
-	PathParts   []PathPart
+	PathParts []PathPart
+	// Depth is the number of directories between the module directory and the package.
+	// The root package has a Depth of zero.
+	Depth       int
 	Name        string
 	ImportPath  string
 	Synopsis    string
 	CommentHtml string
 
+	// Deprecated is true if the package comment has a paragraph that starts with "Deprecated:".
+	Deprecated bool
+	// DeprecatedMessage is the text of the deprecation paragraph, after "Deprecated:".
+	DeprecatedMessage string
+	// Internal is true if the package is in an "internal" directory, so it can only be imported by the packages
+	// in the directory tree that holds the internal directory.
+	Internal bool
+
 	// FileName is the name of the documentation file corresponding to this package.
-	FileName  string
+	FileName string
+	// Files are the paths to the source files the documentation was extracted from, including the _test.go files
+	// that were searched for examples.
+	Files []string
+	// Notes are the notes found in the source of the package, for the markers given by [LoadOptions.NoteMarkers].
+	Notes []Note
+	// Examples are the examples of the package as a whole.
+	Examples  []Example
 	Constants []Constant
 	Variables []Variable
 	Functions []Function
 	Types     []*Type
-	types     map[string]*Type // to manipulate the type after its inserted
+	types     map[string]*Type  // to manipulate the type after its inserted
+	astFiles  []*ast.File       // the parsed source files, without the test files
+	anchors   map[string]string // the anchors of the documented items, see recordAnchors
+	warnings  []string
+	doc       string // the package comment without the doc: commands
 	//paths       map[string]struct{} // the set of valid paths in the package to know if we can link to them
 }
 
+func (p *Package) comment() string { return p.doc }
+
 // HTML should be called from within a template to convert the passed item to html.
-func (p *Package) HTML(t any) string {
+func (p *Package) HTML(t any) (string, error) {
 	switch v := t.(type) {
 	case string:
-		return string(p.DocPkg.HTML(v))
+		return string(p.DocPkg.HTML(v)), nil
 	case HTMLer:
-		return v.toHTML(p)
+		return v.toHTML(p), nil
 	default:
-		panic("cannot convert this type to HTML")
+		return "", fmt.Errorf("cannot convert type %T to HTML", t)
 	}
 }
 
+// Text should be called from within a template to convert the comment of the passed item to plain text.
+func (p *Package) Text(t any) (string, error) {
+	switch v := t.(type) {
+	case string:
+		return string(p.DocPkg.Text(v)), nil
+	case commenter:
+		return string(p.DocPkg.Text(v.comment())), nil
+	default:
+		return "", fmt.Errorf("cannot convert type %T to text", t)
+	}
+}
+
+// NewPackage converts the go doc package p into a Package.
+//
+// If the package is hidden, or has nothing to document, nil is returned.
+// A module that was not returned by [Load] is given the default [LoadOptions], and links only to its own packages.
 func NewPackage(p *doc.Package, fset *token.FileSet, dirPath string, module *Module) *Package {
+	if module.opts == nil {
+		module.opts = new(LoadOptions)
+	}
+	if module.set == nil {
+		module.set = newModuleSet(module.opts)
+		module.set.modules = []*Module{module}
+	}
+	return newPackage(p, fset, dirPath, module, nil)
+}
+
+// newPackage converts the go doc package p into a Package.
+// The files are the parsed source files of the package, which are used to resolve the names of imported packages.
+func newPackage(p *doc.Package, fset *token.FileSet, dirPath string, module *Module, files []*ast.File) *Package {
 	n := new(Package)
+	n.astFiles = files
 	n.DocPkg = p
 	n.Fset = fset
 	n.Module = module
@@ -90,19 +149,29 @@ func NewPackage(p *doc.Package, fset *token.FileSet, dirPath string, module *Mod
 	n.Synopsis = p.Synopsis(p.Doc)
 	n.ImportPath = p.ImportPath
 	n.Path = dirPath
+	if dirPath != "." {
+		n.Depth = strings.Count(dirPath, "/") + 1
+	}
+	n.Internal = isInternal(p.ImportPath)
 	n.FileName = makeFileName(module.Name, dirPath, p.Name)
 	cmt, flags := parseCommentFlags(p.Doc)
-	if _, ok := flags[hideCommand]; ok {
+	n.checkFlags("package "+p.Name, flags)
+	if module.opts.hidden(flags) {
 		// We are being told to hide the package documentation completely
 		return nil
 	}
 	n.types = make(map[string]*Type)
+	n.doc = cmt
 	n.CommentHtml = n.parseHtmlComment(cmt)
+	n.Deprecated, n.DeprecatedMessage = deprecation(cmt)
+	n.Examples = n.parseExamples(p.Examples)
+	n.recordTypeNames()
 	n.parseConstants()
 	n.parseVars()
 	n.parseFuncs()
 	n.parseTypes()
 	n.applyFlags()
+	n.parseNotes()
 
 	// If after all processing, there is nothing to comment, just ignore the whole package
 	if n.CommentHtml == "" &&
@@ -141,6 +210,45 @@ func parseCommentFlags(text string) (newText string, flags map[string]string) {
 	return
 }
 
+// parseFieldFlags returns the comment of a struct field or interface element with the "doc:" commands removed,
+// and the commands of both its doc comment and its line comment.
+// The comment is the doc comment, or the line comment if there is no doc comment.
+func parseFieldFlags(field *ast.Field) (text string, flags map[string]string) {
+	text, flags = parseCommentFlags(field.Doc.Text())
+	lineText, lineFlags := parseCommentFlags(field.Comment.Text())
+	if text == "" {
+		text = lineText
+	}
+	for k, v := range lineFlags {
+		if flags == nil {
+			flags = make(map[string]string)
+		}
+		if _, ok := flags[k]; !ok {
+			flags[k] = v
+		}
+	}
+	return
+}
+
+// checkFlags adds a warning for each doc: command in flags that is not known.
+func (p *Package) checkFlags(itemName string, flags map[string]string) {
+	var unknown []string
+	for k := range flags {
+		if k != hideCommand && k != typeCommand {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		p.warnf("unknown command doc: %s in comment for %s", k, itemName)
+	}
+}
+
+// warnf records a problem found in the documentation.
+func (p *Package) warnf(format string, args ...any) {
+	p.warnings = append(p.warnings, p.Path+": "+fmt.Sprintf(format, args...))
+}
+
 func (p *Package) parseHtmlComment(text string) (html string) {
 	// Parse out the flags and adjust the text
 
@@ -148,14 +256,14 @@ func (p *Package) parseHtmlComment(text string) (html string) {
 	printer := p.DocPkg.Printer()
 
 	printer.DocLinkURL = func(link *comment.DocLink) (url string) {
-		if strings.HasPrefix(link.ImportPath, p.Module.Name) {
-			// A local package, so use the file name
-			l := strings.TrimPrefix(link.ImportPath, p.Module.Name)
-			l = strings.TrimPrefix(l, "/")
-			url = makeFileName(p.Module.Name, l, p.Name)
-		} else if link.ImportPath != "" {
+		if link.ImportPath == "" {
+			// a link within this package
+		} else if u, ok := p.Module.set.packageURL(p.Module, link.ImportPath); ok {
+			// A package documented locally, so use the file name
+			url = u
+		} else {
 			// assume this is a link to the standard library, so point to online doc
-			url = ExternalPackageDoc + link.ImportPath
+			url = p.Module.opts.externalURL(link.ImportPath)
 		}
 
 		if link.Name != "" {
@@ -172,6 +280,16 @@ func (p *Package) parseHtmlComment(text string) (html string) {
 	return
 }
 
+// isInternal returns true if a package with the given import path is an internal package.
+func isInternal(importPath string) bool {
+	for _, part := range strings.Split(importPath, "/") {
+		if part == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
 func makeFileName(importRoot string, dirPath string, packageName string) string {
 	var fileName string
 	if dirPath == "." || dirPath == "" {
@@ -190,42 +308,30 @@ func makeFileName(importRoot string, dirPath string, packageName string) string
 func (p *Package) parseConstant(c *doc.Value) Constant {
 	var c2 Constant
 	c2.Names = c.Names
+	c2.Exported = anyExported(c.Names)
 	cmt, flags := parseCommentFlags(c.Doc)
+	p.checkFlags("constant "+c.Names[0], flags)
 	c2.Flags = flags
+	c2.doc = cmt
+	c2.Deprecated, c2.DeprecatedMessage = deprecation(cmt)
 	c2.CommentHtml = p.parseHtmlComment(cmt)
-	c2.Code, _ = p.generateCode(c.Decl)
+	c2.Code = p.generateCode(c.Decl)
+	c2.decl = c.Decl
 	return c2
 }
 
 func (p *Package) parseConstants() {
 	for _, c := range p.DocPkg.Consts {
 		newC := p.parseConstant(c)
-		if _, ok := newC.Flags[hideCommand]; !ok {
+		if !p.Module.opts.hidden(newC.Flags) {
 			p.Constants = append(p.Constants, newC)
 		}
 	}
 }
 
-func (p *Package) getCodeFragment(start token.Pos, end token.Pos) string {
-	startPosition := p.Fset.Position(start)
-	endPosition := p.Fset.Position(end)
-	if startPosition.Filename != endPosition.Filename {
-		panic("filenames don't match. This is a programming error.")
-	}
-	f, err := os.Open(startPosition.Filename)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer f.Close()
-	b := make([]byte, endPosition.Offset-startPosition.Offset)
-	_, err = f.ReadAt(b, int64(startPosition.Offset))
-	if err != nil {
-		log.Fatal(err)
-	}
-	return string(b)
-}
-
-func (p *Package) generateCode(decl ast.Decl) (string, error) {
+// generateCode returns the formatted source of decl. If it cannot be formatted, a warning is recorded
+// and the empty string is returned.
+func (p *Package) generateCode(decl ast.Decl) string {
 	// Create an AST node slice containing only the target GenDecl.
 	astFile := &ast.File{
 		Name:  ast.NewIdent("main"),
@@ -235,27 +341,33 @@ func (p *Package) generateCode(decl ast.Decl) (string, error) {
 	buf := bytes.Buffer{}
 	err := format.Node(&buf, p.Fset, astFile)
 	if err != nil {
-		return "", err
+		p.warnf("formatting the code of %s: %v", p.Fset.Position(decl.Pos()), err)
+		return ""
 	}
 	s := buf.String()
 	// remove the package preamble
-	return s[14:], nil
+	return s[14:]
 }
 
 func (p *Package) parseVariable(v *doc.Value) Variable {
 	var v2 Variable
 	v2.Names = v.Names
+	v2.Exported = anyExported(v.Names)
 	cmt, flags := parseCommentFlags(v.Doc)
+	p.checkFlags("variable "+v.Names[0], flags)
 	v2.Flags = flags
+	v2.doc = cmt
+	v2.Deprecated, v2.DeprecatedMessage = deprecation(cmt)
 	v2.CommentHtml = p.parseHtmlComment(cmt)
-	v2.Code, _ = p.generateCode(v.Decl)
+	v2.Code = p.generateCode(v.Decl)
+	v2.decl = v.Decl
 	return v2
 }
 
 func (p *Package) parseVars() {
 	for _, v := range p.DocPkg.Vars {
 		newV := p.parseVariable(v)
-		if _, ok := newV.Flags[hideCommand]; !ok {
+		if !p.Module.opts.hidden(newV.Flags) {
 			p.Variables = append(p.Variables, newV)
 		}
 	}
@@ -264,17 +376,24 @@ func (p *Package) parseVars() {
 func (p *Package) parseFunction(f *doc.Func) Function {
 	var f2 Function
 	f2.Name = f.Name
+	f2.Exported = token.IsExported(f.Name)
 	cmt, flags := parseCommentFlags(f.Doc)
+	p.checkFlags("function "+f.Name, flags)
 	f2.Flags = flags
+	f2.doc = cmt
+	f2.Deprecated, f2.DeprecatedMessage = deprecation(cmt)
 	f2.CommentHtml = p.parseHtmlComment(cmt)
-	f2.Code = p.getCodeFragment(f.Decl.Pos(), f.Decl.End())
+	f2.Examples = p.parseExamples(f.Examples)
+	f2.Signature = p.newSignature(nil, f.Decl.Type)
+	f2.Code = p.generateCode(f.Decl)
+	f2.decl = f.Decl
 	return f2
 }
 
 func (p *Package) parseFuncs() {
 	for _, f := range p.DocPkg.Funcs {
 		newF := p.parseFunction(f)
-		if _, ok := newF.Flags[hideCommand]; !ok {
+		if !p.Module.opts.hidden(newF.Flags) {
 			p.Functions = append(p.Functions, newF)
 		}
 	}
@@ -283,10 +402,17 @@ func (p *Package) parseFuncs() {
 func (p *Package) parseMethod(f *doc.Func) Method {
 	var f2 Method
 	f2.Name = f.Name
+	f2.Exported = token.IsExported(f.Name)
 	cmt, flags := parseCommentFlags(f.Doc)
+	p.checkFlags("method "+f.Recv+"."+f.Name, flags)
 	f2.Flags = flags
+	f2.doc = cmt
+	f2.Deprecated, f2.DeprecatedMessage = deprecation(cmt)
 	f2.CommentHtml = p.parseHtmlComment(cmt)
-	f2.Code, _ = p.generateCode(f.Decl)
+	f2.Signature = p.newSignature(f.Decl.Recv, f.Decl.Type)
+	f2.Code = p.generateCode(f.Decl)
+	f2.decl = f.Decl
+	f2.Examples = p.parseExamples(f.Examples)
 
 	f2.Receiver = f.Recv
 	f2.EmbeddedType = f.Orig
@@ -294,12 +420,25 @@ func (p *Package) parseMethod(f *doc.Func) Method {
 	return f2
 }
 
+// recordTypeNames records the names of the documented types before any item is parsed,
+// so that links to types declared later in the package can be made.
+func (p *Package) recordTypeNames() {
+	for _, t := range p.DocPkg.Types {
+		if _, flags := parseCommentFlags(t.Doc); !p.Module.opts.hidden(flags) {
+			p.types[t.Name] = nil
+		}
+	}
+}
+
 func (p *Package) parseTypes() {
 	for _, t := range p.DocPkg.Types {
 		var t2 Type
 		t2.Name = t.Name
+		t2.Exported = token.IsExported(t.Name)
+		t2.spec, _ = t.Decl.Specs[0].(*ast.TypeSpec)
 		typeName := "type"
-		if spec, ok := t.Decl.Specs[0].(*ast.TypeSpec); ok {
+		if spec := t2.spec; spec != nil {
+			t2.TypeParams = p.typeParams(spec.TypeParams)
 			switch spec.Type.(type) {
 			case *ast.StructType:
 				typeName = "struct"
@@ -312,39 +451,57 @@ func (p *Package) parseTypes() {
 			case *ast.InterfaceType:
 				typeName = "interface"
 			}
+			t2.Kind = typeKind(spec)
+			t2.Underlying = p.exprCode(spec.Type)
+			t2.Generic = spec.TypeParams != nil && len(spec.TypeParams.List) > 0
 		}
 		t2.Type = typeName
 
 		cmt, flags := parseCommentFlags(t.Doc)
-		if _, ok := flags[hideCommand]; ok {
+		p.checkFlags("type "+t.Name, flags)
+		if p.Module.opts.hidden(flags) {
 			continue // skip
 		}
 		t2.Flags = flags
+		t2.doc = cmt
+		t2.Deprecated, t2.DeprecatedMessage = deprecation(cmt)
 		t2.CommentHtml = p.parseHtmlComment(cmt)
-		t2.Code, _ = p.generateCode(t.Decl)
+		t2.decl = t.Decl
+		if st, ok := t2.spec.Type.(*ast.StructType); ok {
+			t2.Fields, t2.decl = p.parseFields(&t2, t.Decl, st)
+		} else if it, ok := t2.spec.Type.(*ast.InterfaceType); ok {
+			t2.InterfaceMethods, t2.InterfaceElements, t2.decl = p.parseInterface(&t2, t.Decl, it)
+		}
+		t2.Code = p.generateCode(t2.decl)
+		t2.Examples = p.parseExamples(t.Examples)
 
 		for _, c := range t.Consts {
 			item := p.parseConstant(c)
-			if _, ok := item.Flags[hideCommand]; !ok {
+			if !p.Module.opts.hidden(item.Flags) {
 				t2.Constants = append(t2.Constants, item)
 			}
 		}
 		for _, v := range t.Vars {
 			item := p.parseVariable(v)
-			if _, ok := item.Flags[hideCommand]; !ok {
+			if !p.Module.opts.hidden(item.Flags) {
 				t2.Variables = append(t2.Variables, item)
 			}
 		}
 		for _, f := range t.Funcs {
 			item := p.parseFunction(f)
-			if _, ok := item.Flags[hideCommand]; !ok {
+			if !p.Module.opts.hidden(item.Flags) {
 				t2.Functions = append(t2.Functions, item)
 			}
 		}
 
 		for _, f := range t.Methods {
 			item := p.parseMethod(f)
-			if _, ok := item.Flags[hideCommand]; !ok {
+			if p.Module.opts.hidden(item.Flags) {
+				continue
+			}
+			if f.Level > 0 && p.Module.opts.PromotedMethods {
+				t2.promoted = append(t2.promoted, item)
+			} else {
 				t2.Methods = append(t2.Methods, item)
 			}
 		}
@@ -363,7 +520,7 @@ func (p *Package) applyFlags() {
 			if pT := p.types[t]; pT != nil {
 				pT.Constants = append(pT.Constants, item)
 			} else {
-				log.Printf("Warning: type %s not found in comment for constant %s", t, item.Names[0])
+				p.warnf("type %s not found in comment for constant %s", t, item.Names[0])
 				newConstants = append(newConstants, item)
 			}
 		} else {
@@ -378,7 +535,7 @@ func (p *Package) applyFlags() {
 			if pT := p.types[t]; pT != nil {
 				pT.Variables = append(pT.Variables, item)
 			} else {
-				log.Printf("Warning: type %s not found in comment for variable %s", t, item.Names[0])
+				p.warnf("type %s not found in comment for variable %s", t, item.Names[0])
 				newVars = append(newVars, item)
 			}
 		} else {
@@ -393,7 +550,7 @@ func (p *Package) applyFlags() {
 			if pT := p.types[t]; pT != nil {
 				pT.Functions = append(pT.Functions, item)
 			} else {
-				log.Printf("Warning: type %s not found in comment for function %s", t, item.Name)
+				p.warnf("type %s not found in comment for function %s", t, item.Name)
 				newFuncs = append(newFuncs, item)
 			}
 		} else {
@@ -1,6 +1,10 @@
 package mod
 
 import (
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
 	"reflect"
 	"testing"
 )
@@ -31,3 +35,41 @@ func Test_parseCommentFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	src := `// Package a does things.
+package a
+
+// T is a type. See [F] and [fmt.Stringer].
+type T struct{}
+
+// F makes a T.
+func F() T { return T{} }
+
+// M does things.
+func (T) M() {}
+`
+	f, err := parser.ParseFile(fset, "a.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docPkg, err := doc.NewFromFiles(fset, []*ast.File{f}, "example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPackage(docPkg, fset, ".", &Module{Name: "a", ImportPath: "example.com/a"})
+	if p == nil {
+		t.Fatal("NewPackage() = nil")
+	}
+	if len(p.Types) != 1 || len(p.Types[0].Functions) != 1 || len(p.Types[0].Methods) != 1 {
+		t.Errorf("NewPackage() types = %v, want T with F and M", p.Types)
+	}
+	if p.FileName != "a.html" {
+		t.Errorf("NewPackage() file name = %q, want a.html", p.FileName)
+	}
+	if p = NewPackage(docPkg, fset, ".", &Module{}); p == nil {
+		t.Error("NewPackage() with an empty module = nil")
+	}
+}
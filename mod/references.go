@@ -0,0 +1,167 @@
+package mod
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// Reference is a place in the module set where an exported function, type or method is used.
+//
+// References are only found if requested by [LoadOptions.TypeCheck].
+type Reference struct {
+	// ImportPath is the import path of the package that holds the reference.
+	ImportPath string
+	// Decl is the name of the declaration that holds the reference, like "F", "T" or "T.M" for a method.
+	Decl string
+	// DeclURL is the link to the documentation of the declaration that holds the reference,
+	// or the empty string if the declaration is not documented.
+	DeclURL string
+	// File is the path of the source file holding the reference, relative to the directory given to [Load], or
+	// the directory of the go.work file, separated by "/".
+	File string
+	// Line is the line number of the reference in the file.
+	Line int
+	// URL is the link to the reference in the source of the module, or the empty string if
+	// [LoadOptions.SourceURL] is not set.
+	URL string
+}
+
+// collectReferences records the uses of the exported functions, types and methods of the module set in the
+// package, keyed by the import path and name of the object, like "example.com/a.T.M".
+//
+// It must be called before go/doc removes the function bodies from the syntax trees.
+func (c *typeChecker) collectReferences(importPath string, cp *checkedPackage) {
+	for _, f := range cp.files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				name := d.Name.Name
+				if d.Recv != nil && len(d.Recv.List) > 0 {
+					if id := embeddedName(d.Recv.List[0].Type); id != nil {
+						name = id.Name + "." + name
+					}
+				}
+				// The receiver is left out, as it is part of the declaration of the type.
+				c.recordUses(importPath, cp, name, d.Type)
+				if d.Body != nil {
+					c.recordUses(importPath, cp, name, d.Body)
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						c.recordUses(importPath, cp, s.Name.Name, s)
+					case *ast.ValueSpec:
+						c.recordUses(importPath, cp, s.Names[0].Name, s)
+					}
+				}
+			}
+		}
+	}
+}
+
+// recordUses records the uses found in the node, which is part of the declaration named decl.
+func (c *typeChecker) recordUses(importPath string, cp *checkedPackage, decl string, node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		key, name := c.referenceKey(cp.info.Uses[id])
+		if key == "" || (name == decl && key == importPath+"."+name) {
+			return true // not documented, or a reference of a declaration to itself
+		}
+		pos := c.set.fset.Position(id.Pos())
+		ref := Reference{
+			ImportPath: importPath,
+			Decl:       decl,
+			File:       cp.module.sourcePath(pos.Filename),
+			Line:       pos.Line,
+		}
+		ref.URL = c.set.opts.sourceURL(ref.File, ref.Line)
+		refs := c.references[key]
+		if len(refs) > 0 && refs[len(refs)-1] == ref {
+			return true // the same line
+		}
+		c.references[key] = append(refs, ref)
+		return true
+	})
+}
+
+// referenceKey returns the key of the references to obj, and the name of obj, like "T.M" for a method.
+// The key is the empty string if obj is not an exported function, type or method of a package of the module set.
+func (c *typeChecker) referenceKey(obj types.Object) (key string, name string) {
+	if obj == nil || obj.Pkg() == nil || !obj.Exported() {
+		return "", ""
+	}
+	if _, ok := c.packages[obj.Pkg().Path()]; !ok {
+		return "", ""
+	}
+	name = obj.Name()
+	switch o := obj.(type) {
+	case *types.Func:
+		if recv := o.Type().(*types.Signature).Recv(); recv != nil {
+			recvName := receiverName(recv.Type())
+			if recvName == "" {
+				return "", ""
+			}
+			name = recvName + "." + name
+		} else if o.Parent() != o.Pkg().Scope() {
+			return "", ""
+		}
+	case *types.TypeName:
+		if o.Parent() != o.Pkg().Scope() {
+			return "", ""
+		}
+	default:
+		return "", ""
+	}
+	return obj.Pkg().Path() + "." + name, name
+}
+
+// addReferences sets the References of the exported functions, types and methods of the packages of the set.
+func (s *moduleSet) addReferences() {
+	for _, m := range s.modules {
+		for _, p := range m.PackageList {
+			for i := range p.Functions {
+				p.Functions[i].References = p.references(p.Functions[i].Name)
+			}
+			for _, t := range p.Types {
+				t.References = p.references(t.Name)
+				for i := range t.Functions {
+					t.Functions[i].References = p.references(t.Functions[i].Name)
+				}
+				for i := range t.Methods {
+					t.Methods[i].References = p.references(t.Name + "." + t.Methods[i].Name)
+				}
+				for i := range t.InterfaceMethods {
+					t.InterfaceMethods[i].References = p.references(t.Name + "." + t.InterfaceMethods[i].Name)
+				}
+			}
+		}
+	}
+}
+
+// references returns the references to the object of the package with the given name, like "T.M" for a method,
+// sorted by package, file and line, and linked from the documentation of the package.
+func (p *Package) references(name string) []Reference {
+	refs := p.Module.set.checker.references[p.ImportPath+"."+name]
+	if len(refs) == 0 {
+		return nil
+	}
+	refs = append([]Reference(nil), refs...)
+	for i := range refs {
+		refs[i].DeclURL = p.objectURL(refs[i].ImportPath, refs[i].Decl)
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].ImportPath != refs[j].ImportPath {
+			return refs[i].ImportPath < refs[j].ImportPath
+		}
+		if refs[i].File != refs[j].File {
+			return refs[i].File < refs[j].File
+		}
+		return refs[i].Line < refs[j].Line
+	})
+	return refs
+}
@@ -0,0 +1,105 @@
+package mod
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// Signature represents the signature of a function or method.
+type Signature struct {
+	// Receiver is the receiver of a method, or nil for a function or an interface method.
+	Receiver *Param
+	// PointerReceiver is true if the receiver of the method is a pointer.
+	PointerReceiver bool
+	// TypeParams are the type parameters of a generic function. The Type of each is its constraint.
+	TypeParams []Param
+	Params     []Param
+	Results    []Param
+	// Variadic is true if the last parameter is variadic. Its Type starts with "...".
+	Variadic bool
+}
+
+// Param is a parameter, result, receiver or type parameter of a function.
+type Param struct {
+	// Name is the name of the parameter, or the empty string if it is not named.
+	Name string
+	// Type is the type expression of the parameter.
+	Type string
+	// URL is the link to the documentation of the constraint of a type parameter, if the constraint is a named type.
+	// It is empty for other parameters.
+	URL string
+}
+
+// newSignature returns the signature of a function with the given receiver, type parameters and function type.
+func (p *Package) newSignature(recv *ast.FieldList, ft *ast.FuncType) (s Signature) {
+	if recv != nil && len(recv.List) > 0 {
+		params := p.fieldParams(recv)
+		s.Receiver = &params[0]
+		_, s.PointerReceiver = recv.List[0].Type.(*ast.StarExpr)
+	}
+	s.TypeParams = p.typeParams(ft.TypeParams)
+	s.Params = p.fieldParams(ft.Params)
+	s.Results = p.fieldParams(ft.Results)
+	if n := len(ft.Params.List); n > 0 {
+		_, s.Variadic = ft.Params.List[n-1].Type.(*ast.Ellipsis)
+	}
+	return
+}
+
+// fieldParams returns a Param for each name in the field list, or for each field that has no names.
+func (p *Package) fieldParams(list *ast.FieldList) (params []Param) {
+	if list == nil {
+		return nil
+	}
+	for _, field := range list.List {
+		t := p.exprCode(field.Type)
+		if len(field.Names) == 0 {
+			params = append(params, Param{Type: t})
+			continue
+		}
+		for _, n := range field.Names {
+			params = append(params, Param{Name: n.Name, Type: t})
+		}
+	}
+	return
+}
+
+// typeParams returns the type parameters in the field list, with links to their constraints.
+func (p *Package) typeParams(list *ast.FieldList) (params []Param) {
+	if list == nil {
+		return nil
+	}
+	for _, field := range list.List {
+		url := p.typeExprURL(field.Pos(), field.Type)
+		for _, param := range p.fieldParams(&ast.FieldList{List: []*ast.Field{field}}) {
+			param.URL = url
+			params = append(params, param)
+		}
+	}
+	return
+}
+
+// String returns the signature without the func keyword, name and receiver, like "[T any](x T, y int) (T, error)".
+// Templates can use it for compact index entries.
+func (s Signature) String() string {
+	var b strings.Builder
+	if len(s.TypeParams) > 0 {
+		b.WriteString("[" + paramList(s.TypeParams) + "]")
+	}
+	b.WriteString("(" + paramList(s.Params) + ")")
+	switch {
+	case len(s.Results) == 1 && s.Results[0].Name == "":
+		b.WriteString(" " + s.Results[0].Type)
+	case len(s.Results) > 0:
+		b.WriteString(" (" + paramList(s.Results) + ")")
+	}
+	return b.String()
+}
+
+func paramList(params []Param) string {
+	parts := make([]string, len(params))
+	for i, param := range params {
+		parts[i] = strings.TrimSpace(param.Name + " " + param.Type)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,278 @@
+package mod
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// typeChecker type checks the packages of a module set with go/types.
+//
+// The packages of the set are checked from the syntax trees that were parsed for the documentation, so they
+// must be checked before go/doc removes the unexported declarations and function bodies from the trees.
+// Other imported packages are checked from their source, which is found by the go command run in the directory of
+// the module that imports them, so that the versions required by that module are used. This includes the packages
+// of the module that are not documented.
+type typeChecker struct {
+	set      *moduleSet
+	packages map[string]*checkedPackage  // keyed by import path
+	infos    map[*token.File]*types.Info // the type information of each source file of the set
+	// external are the packages imported from outside the set, keyed by directory.
+	// The package is nil while it is being checked.
+	external map[string]*types.Package
+	// references are the uses of the exported functions, types and methods, see collectReferences.
+	references map[string][]Reference
+}
+
+// checkedPackage is a package of the module set and the result of type checking it.
+type checkedPackage struct {
+	module   *Module
+	relPath  string
+	dirPath  string
+	files    []*ast.File
+	checking bool
+	pkg      *types.Package
+	info     *types.Info
+	err      error // the first error found
+}
+
+// typeCheck type checks the packages of all the modules in the set, and finds the references to their exported
+// functions, types and methods. Problems are added to the warnings of the modules.
+func (s *moduleSet) typeCheck() {
+	c := &typeChecker{
+		set:        s,
+		packages:   make(map[string]*checkedPackage),
+		infos:      make(map[*token.File]*types.Info),
+		external:   make(map[string]*types.Package),
+		references: make(map[string][]Reference),
+	}
+	var importPaths []string
+	for _, m := range s.modules {
+		for _, d := range m.parsed {
+			name, ok := m.pkgNames[d.relPath]
+			if !ok {
+				continue
+			}
+			cp := &checkedPackage{module: m, relPath: d.relPath, dirPath: d.dirPath}
+			for _, f := range d.files[name] {
+				if !strings.HasSuffix(s.fset.File(f.Pos()).Name(), "_test.go") {
+					cp.files = append(cp.files, f)
+				}
+			}
+			importPath := path.Join(m.ImportPath, d.relPath)
+			c.packages[importPath] = cp
+			importPaths = append(importPaths, importPath)
+		}
+	}
+
+	for _, importPath := range importPaths {
+		c.check(importPath, c.packages[importPath])
+	}
+	for _, importPath := range importPaths {
+		cp := c.packages[importPath]
+		if cp.err != nil {
+			cp.module.Warnings = append(cp.module.Warnings, fmt.Sprintf("%s: type checking: %v", cp.relPath, cp.err))
+		}
+	}
+	s.checker = c
+}
+
+// check type checks a package of the set, unless it has already been checked.
+func (c *typeChecker) check(importPath string, cp *checkedPackage) {
+	if cp.pkg != nil || cp.checking {
+		return
+	}
+	cp.checking = true
+	defer func() { cp.checking = false }()
+
+	conf := types.Config{
+		Importer: &sourceImporter{c: c, root: cp.module.dir},
+		Error: func(err error) {
+			if cp.err == nil {
+				cp.err = err
+			}
+		},
+	}
+	cp.info = &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	cp.pkg, _ = conf.Check(importPath, c.set.fset, cp.files, cp.info)
+	for _, f := range cp.files {
+		c.infos[c.set.fset.File(f.Pos())] = cp.info
+	}
+	c.collectReferences(importPath, cp)
+}
+
+// sourceImporter imports the packages for the type checker. The packages of the module set are checked from their
+// syntax trees, and other packages from their source, found by the go command run in the root directory.
+type sourceImporter struct {
+	c    *typeChecker
+	root string
+}
+
+// Import implements [types.Importer].
+func (i *sourceImporter) Import(importPath string) (*types.Package, error) {
+	return i.ImportFrom(importPath, i.root, 0)
+}
+
+// ImportFrom implements [types.ImporterFrom].
+func (i *sourceImporter) ImportFrom(importPath string, dir string, mode types.ImportMode) (*types.Package, error) {
+	if cp, ok := i.c.packages[importPath]; ok {
+		if cp.checking {
+			return nil, fmt.Errorf("import cycle through %s", importPath)
+		}
+		i.c.check(importPath, cp)
+		return cp.pkg, nil
+	}
+	if importPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+
+	ctxt := *i.c.set.buildContext
+	ctxt.Dir = i.root
+	ctxt.CgoEnabled = false // the files that use cgo cannot be checked without running cgo
+	bp, err := ctxt.Import(importPath, dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pkg, ok := i.c.external[bp.Dir]; ok {
+		if pkg == nil {
+			return nil, fmt.Errorf("import cycle through %s", importPath)
+		}
+		return pkg, nil
+	}
+	i.c.external[bp.Dir] = nil
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(i.c.set.fset, filepath.Join(bp.Dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			delete(i.c.external, bp.Dir)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{
+		Importer:         i,
+		IgnoreFuncBodies: true,
+		Error:            func(error) {}, // only the problems of the packages of the set are reported
+	}
+	pkg, _ := conf.Check(bp.ImportPath, i.c.set.fset, files, nil)
+	i.c.external[bp.Dir] = pkg
+	return pkg, nil
+}
+
+// info returns the type information of the source file holding pos, or nil if the file was not type checked.
+func (c *typeChecker) info(pos token.Pos) *types.Info {
+	return c.infos[c.set.fset.File(pos)]
+}
+
+// typeName returns the type checked object of the type t of package p, or nil if the package was not type checked.
+func (c *typeChecker) typeName(p *Package, t *Type) *types.TypeName {
+	cp := c.packages[p.ImportPath]
+	if cp == nil || cp.pkg == nil {
+		return nil
+	}
+	obj, _ := cp.pkg.Scope().Lookup(t.Name).(*types.TypeName)
+	return obj
+}
+
+// Implementation is a concrete type that implements an interface, or an interface that a concrete type implements.
+type Implementation struct {
+	// Name is the name of the type, qualified with the package name if the type is declared in another package.
+	Name string
+	// ImportPath is the import path of the package that declares the type.
+	ImportPath string
+	// URL is the link to the documentation of the type.
+	URL string
+	// Pointer is true if only the pointer to the concrete type implements the interface,
+	// because some of the methods have pointer receivers.
+	Pointer bool
+}
+
+// implementation returns the Implementation that links from the documentation of p to the type t of package p2.
+func (p *Package) implementation(p2 *Package, t *Type, pointer bool) Implementation {
+	impl := Implementation{Name: t.Name, ImportPath: p2.ImportPath, URL: "#" + t.Name, Pointer: pointer}
+	if p2 != p {
+		impl.Name = p2.Name + "." + t.Name
+		url, _ := p.Module.set.packageURL(p.Module, p2.ImportPath)
+		impl.URL = url + "#" + t.Name
+	}
+	return impl
+}
+
+// findImplementations finds the concrete types of the module set that implement each interface of the module set.
+//
+// Generic types, and interfaces that have no methods or that can only be used as constraints, are left out.
+func (s *moduleSet) findImplementations() {
+	type namedType struct {
+		p     *Package
+		t     *Type
+		named *types.Named
+	}
+	var interfaces, concrete []namedType
+	for _, m := range s.modules {
+		for _, p := range m.PackageList {
+			for _, t := range p.Types {
+				obj := s.checker.typeName(p, t)
+				if obj == nil || obj.IsAlias() {
+					continue
+				}
+				named, ok := obj.Type().(*types.Named)
+				if !ok || named.TypeParams().Len() > 0 {
+					continue
+				}
+				if iface, ok := named.Underlying().(*types.Interface); ok {
+					if iface.IsMethodSet() && iface.NumMethods() > 0 {
+						interfaces = append(interfaces, namedType{p, t, named})
+					}
+				} else {
+					concrete = append(concrete, namedType{p, t, named})
+				}
+			}
+		}
+	}
+
+	for _, i := range interfaces {
+		iface := i.named.Underlying().(*types.Interface)
+		for _, c := range concrete {
+			pointer := false
+			if !types.Implements(c.named, iface) {
+				if _, ok := c.named.Underlying().(*types.Pointer); ok || !types.Implements(types.NewPointer(c.named), iface) {
+					continue
+				}
+				pointer = true
+			}
+			i.t.Implementations = append(i.t.Implementations, i.p.implementation(c.p, c.t, pointer))
+			c.t.Implements = append(c.t.Implements, c.p.implementation(i.p, i.t, pointer))
+		}
+	}
+	for _, m := range s.modules {
+		for _, p := range m.PackageList {
+			for _, t := range p.Types {
+				sortImplementations(t.Implementations)
+				sortImplementations(t.Implements)
+			}
+		}
+	}
+}
+
+// sortImplementations sorts the types of the same package first, then by import path and name.
+func sortImplementations(list []Implementation) {
+	sort.SliceStable(list, func(i, j int) bool {
+		local1, local2 := strings.HasPrefix(list[i].URL, "#"), strings.HasPrefix(list[j].URL, "#")
+		if local1 != local2 {
+			return local1
+		}
+		if list[i].ImportPath != list[j].ImportPath {
+			return list[i].ImportPath < list[j].ImportPath
+		}
+		return list[i].Name < list[j].Name
+	})
+}
@@ -1,11 +1,29 @@
 package mod
 
+import (
+	"go/ast"
+	"go/token"
+)
+
 // Constant represents a single line constant or a constant group declaration
 type Constant struct {
 	Code        string
 	Names       []string
 	CommentHtml string
 	Flags       map[string]string
+	// Exported is true if any of the names is exported.
+	Exported bool
+
+	// CodeHtml is Code as HTML. If the module is type checked, the identifiers are linked to their documentation.
+	CodeHtml string
+
+	// Deprecated is true if the comment has a paragraph that starts with "Deprecated:".
+	Deprecated bool
+	// DeprecatedMessage is the text of the deprecation paragraph, after "Deprecated:".
+	DeprecatedMessage string
+
+	doc  string   // the comment with the doc: commands removed
+	decl ast.Decl // the declaration that Code is generated from
 }
 
 // Variable represents a variable declaration, or a group of variables declared together with the same type.
@@ -14,20 +32,52 @@ type Variable struct {
 	Names       []string
 	CommentHtml string
 	Flags       map[string]string
+	// Exported is true if any of the names is exported.
+	Exported bool
+
+	// CodeHtml is Code as HTML. If the module is type checked, the identifiers are linked to their documentation.
+	CodeHtml string
+
+	// Deprecated is true if the comment has a paragraph that starts with "Deprecated:".
+	Deprecated bool
+	// DeprecatedMessage is the text of the deprecation paragraph, after "Deprecated:".
+	DeprecatedMessage string
+
+	doc  string   // the comment with the doc: commands removed
+	decl ast.Decl // the declaration that Code is generated from
 }
 
 // Function represents a simple top-level function that is not associated with a type.
 type Function struct {
 	Code        string
 	Name        string
+	Signature   Signature
 	CommentHtml string
 	Flags       map[string]string
+	Examples    []Example
+	Exported    bool
+
+	// References are the places in the module set where the function is used, outside of its own declaration.
+	// They are only found if requested by [LoadOptions.TypeCheck].
+	References []Reference
+
+	// CodeHtml is Code as HTML. If the module is type checked, the identifiers are linked to their documentation.
+	CodeHtml string
+
+	// Deprecated is true if the comment has a paragraph that starts with "Deprecated:".
+	Deprecated bool
+	// DeprecatedMessage is the text of the deprecation paragraph, after "Deprecated:".
+	DeprecatedMessage string
+
+	doc  string
+	decl ast.Decl // the declaration that Code is generated from
 }
 
 // Method represents a method associated with a type.
 type Method struct {
 	Code        string
 	Name        string
+	Signature   Signature
 	CommentHtml string
 
 	// The type of the receiver
@@ -36,8 +86,55 @@ type Method struct {
 	EmbeddedType string
 	Level        int
 	Flags        map[string]string
+	Examples     []Example
+	Exported     bool
+
+	// References are the places in the module set where the method is used, outside of its own declaration.
+	// They are only found if requested by [LoadOptions.TypeCheck].
+	References []Reference
+
+	// CodeHtml is Code as HTML. If the module is type checked, the identifiers are linked to their documentation.
+	CodeHtml string
+
+	// Deprecated is true if the comment has a paragraph that starts with "Deprecated:".
+	Deprecated bool
+	// DeprecatedMessage is the text of the deprecation paragraph, after "Deprecated:".
+	DeprecatedMessage string
+
+	doc   string
+	decl  ast.Decl   // the declaration that Code is generated from
+	field *ast.Field // the method of an interface type that Code is generated from, instead of decl
 }
 
+// node returns the syntax that Code is generated from, or nil if there is none.
+func (m *Method) node() ast.Node {
+	if m.field != nil {
+		return m.field
+	}
+	if m.decl != nil {
+		return m.decl
+	}
+	return nil
+}
+
+// Kinds of types.
+const (
+	// KindAlias is an alias for another type, like "type A = B".
+	KindAlias = "alias"
+	// KindBasic is a type defined with a predeclared basic type, like "type Weekday int".
+	KindBasic     = "basic"
+	KindStruct    = "struct"
+	KindInterface = "interface"
+	KindMap       = "map"
+	KindPointer   = "pointer"
+	KindSlice     = "slice"
+	KindArray     = "array"
+	KindChan      = "chan"
+	KindFunc      = "func"
+	// KindNamed is a type defined with another named type, like "type D time.Duration".
+	KindNamed = "named"
+)
+
 // Type represents a type definition.
 // This could by a simple type, an interface, or a structure
 type Type struct {
@@ -45,9 +142,150 @@ type Type struct {
 	Name        string
 	CommentHtml string
 	Flags       map[string]string
-	Type        string // If we know its one of the types we can determine, we will name it
-	Constants   []Constant
-	Variables   []Variable
-	Functions   []Function
-	Methods     []Method
+	// Type is "struct", "interface", "[]", "chan" or "func" for those kinds of types, and "type" for the others.
+	//
+	// Deprecated: Use Kind, which distinguishes all the kinds of types.
+	Type string
+	// Kind is the kind of the type, which is one of the Kind constants.
+	Kind string
+	// Underlying is the type expression the type is defined with, like "int" or "map[string]int".
+	// For an alias, it is the type the alias stands for.
+	Underlying string
+	// Generic is true if the type has type parameters.
+	Generic bool
+	// TypeParams are the type parameters of a generic type. The Type of each is its constraint.
+	TypeParams []Param
+	// Fields are the fields of a struct type, in declaration order.
+	Fields []Field
+	// InterfaceMethods are the methods declared in an interface type, in declaration order.
+	InterfaceMethods []Method
+	// InterfaceElements are the embedded interfaces and type constraint elements of an interface type.
+	InterfaceElements []InterfaceElement
+	Constants         []Constant
+	Variables         []Variable
+	Functions         []Function
+	Methods           []Method
+	// Inherited are the methods promoted from embedded types, grouped by the type they are declared on.
+	// They are only found if requested by [LoadOptions.PromotedMethods].
+	Inherited []InheritedMethods
+	// Implementations are the concrete types of the module that implement the interface, if this is an interface type.
+	// Implements are the interfaces of the module that this concrete type implements.
+	// They are only found if requested by [LoadOptions.TypeCheck].
+	Implementations []Implementation
+	Implements      []Implementation
+	Examples        []Example
+	Exported        bool
+
+	// References are the places in the module set where the type is used, outside of its own declaration.
+	// They are only found if requested by [LoadOptions.TypeCheck].
+	References []Reference
+
+	// CodeHtml is Code as HTML. If the module is type checked, the identifiers are linked to their documentation.
+	CodeHtml string
+
+	// Deprecated is true if the comment has a paragraph that starts with "Deprecated:".
+	Deprecated bool
+	// DeprecatedMessage is the text of the deprecation paragraph, after "Deprecated:".
+	DeprecatedMessage string
+
+	doc      string
+	spec     *ast.TypeSpec
+	decl     ast.Decl // the declaration that Code is generated from
+	promoted []Method // the promoted methods found by go/doc, see addInheritedMethods
+}
+
+// InheritedMethods are the methods a type inherits from one of its embedded types.
+type InheritedMethods struct {
+	// From is the type the methods are declared on, like "*Base", or "other.Base" for a type in another package.
+	From string
+	// URL is the link to the documentation of the type the methods are declared on,
+	// or the empty string if it is not documented.
+	URL     string
+	Methods []Method
+}
+
+// MethodURL returns the link to the documentation of the inherited method m,
+// or the empty string if it is not documented.
+func (g InheritedMethods) MethodURL(m Method) string {
+	if g.URL == "" {
+		return ""
+	}
+	return g.URL + "." + m.Name
+}
+
+// Example represents a testable example function found in a _test.go file.
+type Example struct {
+	// Name is the name of the item the example documents, followed by the suffix.
+	Name string
+	// Suffix is the part of the example function name that distinguishes multiple examples of the same item,
+	// without the leading "_", or the empty string.
+	Suffix string
+	// Code is the body of the example function, without the output comment.
+	Code        string
+	CommentHtml string
+	Flags       map[string]string
+	// Output is the expected output given by the output comment.
+	Output string
+	// Unordered is true if the lines of the output may appear in any order.
+	Unordered bool
+	// EmptyOutput is true if the example has an output comment that expects no output.
+	EmptyOutput bool
+
+	doc string
 }
+
+// anyExported returns true if any of the names is exported.
+func anyExported(names []string) bool {
+	for _, n := range names {
+		if token.IsExported(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeKind returns the kind of the type declared by spec.
+func typeKind(spec *ast.TypeSpec) string {
+	if spec.Assign.IsValid() {
+		return KindAlias
+	}
+	expr := spec.Type
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		expr = paren.X
+	}
+	switch e := expr.(type) {
+	case *ast.StructType:
+		return KindStruct
+	case *ast.InterfaceType:
+		return KindInterface
+	case *ast.MapType:
+		return KindMap
+	case *ast.StarExpr:
+		return KindPointer
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return KindSlice
+		}
+		return KindArray
+	case *ast.ChanType:
+		return KindChan
+	case *ast.FuncType:
+		return KindFunc
+	case *ast.Ident:
+		if predeclaredTypes[e.Name] && e.Name != "any" && e.Name != "comparable" && e.Name != "error" {
+			return KindBasic
+		}
+	}
+	return KindNamed
+}
+
+func (c Constant) comment() string { return c.doc }
+func (v Variable) comment() string { return v.doc }
+func (f Function) comment() string { return f.doc }
+func (m Method) comment() string   { return m.doc }
+func (t Type) comment() string     { return t.doc }
+func (e Example) comment() string  { return e.doc }
@@ -0,0 +1,147 @@
+package mod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/mod/modfile"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Workspace represents the documentation of the modules of a go.work workspace.
+//
+// Workspace is structured to be easily consumed by Go templates.
+type Workspace struct {
+	// DirName is the name of the directory holding the go.work file.
+	DirName string
+	// IndexFile is the name of the file holding the workspace index.
+	IndexFile string
+	// Modules are the modules named by the use directives of the go.work file, sorted by Path.
+	Modules []*Module
+}
+
+// LoadWorkspace reads a go.work file and loads each module it uses, returning a Workspace structure.
+//
+// workPath is the path to the go.work file, or to the directory that holds it. If there is no such file,
+// the returned error will wrap [ErrNoWorkFile]. Otherwise, errors are returned as described by [Load].
+//
+// The Path of each module is relative to the directory of the go.work file, and the documentation of the module is
+// written to a DocDir with the same path, so that doc links between the modules of the workspace are resolved locally.
+// Nested modules that are not used by the workspace are not documented. A module that has no packages to document,
+// for example because of [LoadOptions.Include], is left out. If no module has packages to document, the returned
+// error wraps [ErrNoPackages].
+func LoadWorkspace(ctx context.Context, workPath string, opts *LoadOptions) (*Workspace, error) {
+	if opts == nil {
+		opts = new(LoadOptions)
+	}
+	workPath, err := filepath.Abs(workPath)
+	if err != nil {
+		return nil, &SourceError{Path: workPath, Err: err}
+	}
+	if info, err := os.Stat(workPath); err == nil && info.IsDir() {
+		workPath = filepath.Join(workPath, "go.work")
+	}
+	f, err := readWorkFile(workPath)
+	if err != nil {
+		return nil, err
+	}
+	workDir := filepath.Dir(workPath)
+
+	ws := &Workspace{
+		DirName:   filepath.Base(workDir),
+		IndexFile: opts.IndexFileName(),
+	}
+	s := newModuleSet(opts)
+	docDirs := make(map[string]bool)
+	for _, use := range f.Use {
+		dir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+		relPath, err := filepath.Rel(workDir, dir)
+		if err != nil {
+			relPath = dir
+		}
+		relPath = filepath.ToSlash(relPath)
+		m, _, err := s.addModule(ctx, dir, relPath, "")
+		if errors.Is(err, ErrNoPackages) {
+			continue // the options leave nothing to document in the module
+		} else if err != nil {
+			return nil, err
+		}
+		m.Workspace = ws
+		m.DocDir = workspaceDocDir(m, docDirs)
+		ws.Modules = append(ws.Modules, m)
+	}
+	if len(ws.Modules) == 0 {
+		return nil, &SourceError{Path: workPath, Err: ErrNoPackages}
+	}
+	sort.Slice(ws.Modules, func(i, j int) bool {
+		return packagePathLess(ws.Modules[i].Path, ws.Modules[j].Path)
+	})
+
+	if err = s.build(ctx); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// readWorkFile reads and parses the go.work file at workPath.
+func readWorkFile(workPath string) (*modfile.WorkFile, error) {
+	b, err := os.ReadFile(workPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &ModFileError{Path: workPath, Err: ErrNoWorkFile}
+	} else if err != nil {
+		return nil, &ModFileError{Path: workPath, Err: err}
+	}
+	f, err := modfile.ParseWork(workPath, b, nil)
+	if err != nil {
+		return nil, &ModFileError{Path: workPath, Err: err}
+	}
+	if len(f.Use) == 0 {
+		return nil, &ModFileError{Path: workPath, Err: errors.New("no use directives")}
+	}
+	return f, nil
+}
+
+// workspaceDocDir returns the directory, relative to the workspace documentation, to write the documentation of m to.
+//
+// This is the Path of the module, with the parts that would leave the output directory replaced. The workspace index
+// is written to the root, so a module in the workspace directory is documented in a directory named after the module.
+// used records the directories already taken, so that each module gets its own.
+func workspaceDocDir(m *Module, used map[string]bool) string {
+	var parts []string
+	for _, part := range strings.Split(m.Path, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			parts = append(parts, "_")
+		default:
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, path.Base(m.ImportPath))
+	}
+	docDir := strings.Join(parts, "/")
+	for i := 2; used[docDir]; i++ {
+		docDir = fmt.Sprintf("%s_%d", strings.Join(parts, "/"), i)
+	}
+	used[docDir] = true
+	return docDir
+}
+
+// ModuleURL returns the relative URL from the workspace index to the index of the module m.
+func (ws *Workspace) ModuleURL(m *Module) string {
+	return relURL(".", m.DocDir) + m.IndexFile
+}
+
+// PackageURL returns the relative URL from the workspace index to the documentation of the package p.
+func (ws *Workspace) PackageURL(p *Package) string {
+	return relURL(".", p.Module.DocDir) + p.FileName
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/goradd/moddoc/mod"
+	"io"
+	"os"
+	"strings"
+)
+
+func queryFlags(fs *flag.FlagSet) func(args []string) error {
+	modFlags := addModuleFlags(fs)
+	return func(args []string) error {
+		if len(args) > 1 {
+			return usageError{"query takes at most one argument"}
+		}
+		cfg, err := modFlags.config()
+		if err != nil {
+			return err
+		}
+		d, err := modFlags.load(cfg)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			listPackages(os.Stdout, d)
+			return nil
+		}
+		if d.workspace != nil {
+			return queryWorkspace(os.Stdout, d.workspace, args[0])
+		}
+		return query(os.Stdout, d.module, args[0])
+	}
+}
+
+// listPackages prints the path and synopsis of every package in the module.
+// The packages of a workspace are listed by import path.
+func listPackages(w io.Writer, d *docs) {
+	for _, m := range d.modules() {
+		for _, pkg := range m.PackageList {
+			p := pkg.Path
+			if d.workspace != nil {
+				p = pkg.ImportPath
+			}
+			fmt.Fprintf(w, "%-30s %s\n", p, pkg.Synopsis)
+		}
+	}
+}
+
+// queryWorkspace prints the documentation of the package or item named by q in a workspace.
+//
+// q starts with the import path of a package, and is otherwise the same as described by [query].
+func queryWorkspace(w io.Writer, ws *mod.Workspace, q string) error {
+	var found *mod.Module
+	for _, m := range ws.Modules {
+		if strings.HasPrefix(q, m.ImportPath) && (found == nil || len(m.ImportPath) > len(found.ImportPath)) {
+			found = m
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("no module of the workspace contains %s", q)
+	}
+	rest := strings.TrimPrefix(q, found.ImportPath)
+	switch {
+	case rest == "":
+		rest = "."
+	case rest[0] == '/' || rest[0] == '.':
+		rest = rest[1:]
+	default:
+		return fmt.Errorf("no module of the workspace contains %s", q)
+	}
+	return query(w, found, rest)
+}
+
+// query prints the documentation of the package or item named by q.
+//
+// q is a package path relative to the module directory, optionally followed by a "." and the name of an item in
+// the package. Items in the root package can be named without a package path.
+func query(w io.Writer, m *mod.Module, q string) error {
+	if pkg := m.Package(q); pkg != nil {
+		printPackage(w, pkg)
+		return nil
+	}
+
+	pkgPath, name := ".", q
+	if i := strings.LastIndex(q, "/"); i >= 0 {
+		if j := strings.Index(q[i:], "."); j >= 0 {
+			pkgPath, name = q[:i+j], q[i+j+1:]
+		}
+	} else if j := strings.Index(q, "."); j >= 0 {
+		if m.Package(q[:j]) != nil {
+			pkgPath, name = q[:j], q[j+1:]
+		}
+	}
+	pkg := m.Package(pkgPath)
+	if pkg == nil {
+		return fmt.Errorf("package %s not found", pkgPath)
+	}
+	item := lookupItem(pkg, name)
+	if item == nil {
+		return fmt.Errorf("%s not found in package %s", name, pkgPath)
+	}
+	code, _ := itemCode(item)
+	fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(code))
+	text, err := pkg.Text(item)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, text)
+	return nil
+}
+
+// printPackage prints the package comment and the names of the items in the package.
+func printPackage(w io.Writer, pkg *mod.Package) {
+	fmt.Fprintf(w, "package %s // import %q\n\n", pkg.Name, pkg.ImportPath)
+	text, _ := pkg.Text(pkg)
+	fmt.Fprint(w, text)
+	printSection := func(title string, lines []string) {
+		if len(lines) > 0 {
+			fmt.Fprintf(w, "\n%s\n", title)
+			for _, l := range lines {
+				fmt.Fprintf(w, "    %s\n", l)
+			}
+		}
+	}
+	var lines []string
+	for _, c := range pkg.Constants {
+		lines = append(lines, c.Names...)
+	}
+	printSection("CONSTANTS", lines)
+	lines = nil
+	for _, v := range pkg.Variables {
+		lines = append(lines, v.Names...)
+	}
+	printSection("VARIABLES", lines)
+	lines = nil
+	for _, f := range pkg.Functions {
+		lines = append(lines, f.Name)
+	}
+	printSection("FUNCTIONS", lines)
+	lines = nil
+	for _, t := range pkg.Types {
+		lines = append(lines, t.Name)
+		for _, f := range t.Functions {
+			lines = append(lines, "    "+f.Name)
+		}
+		for _, m := range t.Methods {
+			lines = append(lines, "    "+t.Name+"."+m.Name)
+		}
+	}
+	printSection("TYPES", lines)
+}
+
+// lookupItem finds the item named name in pkg, returning nil if it is not found.
+// The name of a method is given as Type.Method. The methods of an interface, and the methods promoted from
+// embedded types if they are documented, are found the same way.
+func lookupItem(pkg *mod.Package, name string) any {
+	typeName, memberName, isMember := strings.Cut(name, ".")
+	for _, t := range pkg.Types {
+		if t.Name != typeName {
+			if item := findValue(t.Constants, t.Variables, t.Functions, name); item != nil {
+				return item
+			}
+			continue
+		}
+		if !isMember {
+			return *t
+		}
+		for _, m := range t.Methods {
+			if m.Name == memberName {
+				return m
+			}
+		}
+		for _, m := range t.InterfaceMethods {
+			if m.Name == memberName {
+				return m
+			}
+		}
+		for _, g := range t.Inherited {
+			for _, m := range g.Methods {
+				if m.Name == memberName {
+					return m
+				}
+			}
+		}
+		return findValue(t.Constants, t.Variables, t.Functions, memberName)
+	}
+	return findValue(pkg.Constants, pkg.Variables, pkg.Functions, name)
+}
+
+func findValue(constants []mod.Constant, variables []mod.Variable, functions []mod.Function, name string) any {
+	for _, c := range constants {
+		for _, n := range c.Names {
+			if n == name {
+				return c
+			}
+		}
+	}
+	for _, v := range variables {
+		for _, n := range v.Names {
+			if n == name {
+				return v
+			}
+		}
+	}
+	for _, f := range functions {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// itemCode returns the declaration of an item returned by lookupItem.
+func itemCode(item any) (string, bool) {
+	switch v := item.(type) {
+	case mod.Constant:
+		return v.Code, true
+	case mod.Variable:
+		return v.Code, true
+	case mod.Function:
+		return v.Code, true
+	case mod.Method:
+		return v.Code, true
+	case mod.Type:
+		return v.Code, true
+	}
+	return "", false
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"github.com/goradd/moddoc/mod"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n",
+		"b/b.go": "// Package b does things.\npackage b\n\n// T is a thing.\ntype T int\n\n// M does things.\nfunc (T) M() {}\n\n" +
+			"// Reader reads.\ntype Reader interface {\n\t// Read reads into p.\n\tRead(p []byte) (int, error)\n}\n",
+		"c/c.go": "// Package c has a doc: command.\n//\n// doc: type=T\npackage c\n\n// F does things.\nfunc F() {}\n",
+	})
+	m, err := mod.Load(context.Background(), src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{".", []string{"package a", "Package a does things.", "FUNCTIONS", "F"}},
+		{"F", []string{"func F()", "F does things."}},
+		{"b", []string{"package b", "TYPES", "T.M"}},
+		{"b.T", []string{"type T int", "T is a thing."}},
+		{"b.T.M", []string{"func (T) M()", "M does things."}},
+		{"b.Reader.Read", []string{"Read(p []byte) (int, error)", "Read reads into p."}},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		if err := query(&buf, m, tt.q); err != nil {
+			t.Errorf("%s: %v", tt.q, err)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("%s: output does not contain %q:\n%s", tt.q, w, buf.String())
+			}
+		}
+	}
+
+	var buf strings.Builder
+	if err := query(&buf, m, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "doc: type") {
+		t.Errorf("the package comment of c is printed with its doc: commands:\n%s", buf.String())
+	}
+
+	for _, q := range []string{"d", "G", "b.T.N"} {
+		if err := query(&strings.Builder{}, m, q); err == nil {
+			t.Errorf("%s: no error for a missing item", q)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"github.com/goradd/moddoc/mod"
+	"html"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadScript is inserted into every page served so that the browser reloads the page when the source changes.
+const reloadScript = `<script>new EventSource("/_moddoc/events").onmessage = function() { location.reload(); };</script>`
+
+// docServer renders the documentation of a module or workspace on demand, reloading it when its source changes.
+type docServer struct {
+	modFlags  *moduleFlags
+	tmplFlags *templateFlags
+	staticDir string
+	loadDocs  func(cfg *config) (*docs, error) // loads the documentation, replaced by the tests
+
+	mu          sync.Mutex
+	docs        *docs
+	templates   templates
+	indexFile   string
+	loadErr     error
+	fingerprint string
+	changed     chan struct{} // closed and replaced whenever the source changes
+	loading     chan struct{} // closed when the load in progress ends, or nil if there is none
+}
+
+func serveFlags(fs *flag.FlagSet) func(args []string) error {
+	s := newDocServer(fs)
+	staticPath := fs.String("s", "", "The directory of static files to serve, like styles.css. Will use the module directory by default.")
+	addr := fs.String("http", "localhost:6060", "The address to serve on.")
+	poll := fs.Duration("poll", 500*time.Millisecond, "How often to check the source files for changes.")
+
+	return func(args []string) error {
+		srcDir, err := s.modFlags.srcDir()
+		if err != nil {
+			return err
+		}
+		if err = s.init(*staticPath); err != nil {
+			return err
+		}
+		go s.watch(*poll)
+
+		log.Printf("serving documentation for %s at http://%s", srcDir, *addr)
+		return http.ListenAndServe(*addr, s)
+	}
+}
+
+// newDocServer returns a docServer whose module and template flags are added to fs.
+func newDocServer(fs *flag.FlagSet) *docServer {
+	s := &docServer{
+		modFlags:  addModuleFlags(fs),
+		tmplFlags: addTemplateFlags(fs),
+		changed:   make(chan struct{}),
+	}
+	s.loadDocs = s.modFlags.load
+	return s
+}
+
+// init prepares the server once the flags are parsed. The static files are served from staticPath, or from the
+// source directory if it is empty.
+func (s *docServer) init(staticPath string) error {
+	srcDir, err := s.modFlags.srcDir()
+	if err != nil {
+		return err
+	}
+	s.staticDir = srcDir
+	if staticPath != "" {
+		if s.staticDir, err = filepath.Abs(staticPath); err != nil {
+			return err
+		}
+	}
+	s.fingerprint = s.sourceFingerprint()
+
+	// Route the requests for the index with the configured name until the documentation is loaded.
+	opts := new(mod.LoadOptions)
+	if cfg, err := s.modFlags.config(); err == nil {
+		opts = s.modFlags.options(cfg)
+	}
+	s.indexFile = opts.IndexFileName()
+	return nil
+}
+
+// ServeHTTP serves the index pages, the package pages, the reload events and the static files.
+//
+// The pages of a nested module, or of a module in a workspace, are served from the DocDir of the module.
+func (s *docServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dir, name := path.Split(path.Clean(r.URL.Path))
+	if strings.HasSuffix(r.URL.Path, "/") {
+		dir, name = path.Clean(r.URL.Path), s.currentIndexFile()
+	}
+	docDir := strings.Trim(dir, "/")
+	if docDir == "" {
+		docDir = "."
+	}
+	switch {
+	case r.URL.Path == "/_moddoc/events":
+		s.serveEvents(w, r)
+	case name == s.currentIndexFile():
+		s.servePage(w, func(d *docs, t templates, buf *bytes.Buffer) (bool, error) {
+			if d.workspace != nil && docDir == "." {
+				return true, t.workspace.Execute(buf, d.workspace)
+			}
+			if m := d.moduleByDocDir(docDir); m != nil {
+				return true, t.index.Execute(buf, m)
+			}
+			return false, nil
+		})
+	case strings.HasSuffix(name, ".html"):
+		s.servePage(w, func(d *docs, t templates, buf *bytes.Buffer) (bool, error) {
+			if m := d.moduleByDocDir(docDir); m != nil {
+				if pkg := m.PackageByFileName(name); pkg != nil {
+					return true, t.pkg.Execute(buf, pkg)
+				}
+				if name == m.DeprecatedFile && len(m.Deprecated) > 0 {
+					return true, t.deprecated.Execute(buf, m)
+				}
+				if name == m.NotesFile && len(m.Notes) > 0 {
+					return true, t.notes.Execute(buf, m)
+				}
+			}
+			return false, nil
+		})
+	default:
+		http.FileServer(http.Dir(s.staticDir)).ServeHTTP(w, r)
+	}
+}
+
+// currentIndexFile returns the name of the index file of the module last loaded.
+func (s *docServer) currentIndexFile() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.indexFile
+}
+
+// servePage renders a page with the current documentation and templates and writes it with the reload script added.
+// The render function returns false if the page does not exist.
+func (s *docServer) servePage(w http.ResponseWriter, render func(d *docs, t templates, buf *bytes.Buffer) (bool, error)) {
+	var buf bytes.Buffer
+	d, t, err := s.load()
+	found := true
+	if err == nil {
+		found, err = render(d, t, &buf)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html><body><pre>%s</pre>%s</body></html>", html.EscapeString(err.Error()), reloadScript)
+		return
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html><body><p>Page not found.</p>%s</body></html>", reloadScript)
+		return
+	}
+	page := buf.String()
+	if i := strings.LastIndex(page, "</body>"); i >= 0 {
+		page = page[:i] + reloadScript + page[i:]
+	} else {
+		page += reloadScript
+	}
+	_, _ = w.Write([]byte(page))
+}
+
+// serveEvents sends a server-sent event to the browser each time the source changes.
+func (s *docServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+			if _, err := fmt.Fprint(w, "data: reload\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// load returns the current documentation and templates, loading them if the source changed since they were last loaded.
+//
+// The templates are returned with the documentation, rather than read from s by the caller, so that a page is
+// rendered with the templates loaded together with its documentation, even if the source changes meanwhile.
+// The lock is not held while loading, so that other requests are not blocked. Requests that arrive while loading
+// wait for that load instead of starting their own. If the source changes while loading, the result is returned
+// but not kept, and the waiting requests load again.
+func (s *docServer) load() (*docs, templates, error) {
+	s.mu.Lock()
+	for s.docs == nil && s.loadErr == nil && s.loading != nil {
+		loading := s.loading
+		s.mu.Unlock()
+		<-loading
+		s.mu.Lock()
+	}
+	if s.docs != nil || s.loadErr != nil {
+		defer s.mu.Unlock()
+		return s.docs, s.templates, s.loadErr
+	}
+	changed := s.changed
+	loading := make(chan struct{})
+	s.loading = loading
+	s.mu.Unlock()
+
+	var d *docs
+	var t templates
+	var indexFile string
+	cfg, err := s.modFlags.config()
+	if err == nil {
+		indexFile = s.modFlags.options(cfg).IndexFileName()
+		t, err = s.tmplFlags.load(cfg)
+	}
+	if err == nil {
+		d, err = s.loadDocs(cfg)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loading = nil
+	close(loading)
+	if changed == s.changed {
+		s.docs, s.templates, s.loadErr = d, t, err
+		if indexFile != "" {
+			s.indexFile = indexFile
+		}
+	}
+	return d, t, err
+}
+
+// watch polls the source for changes, and when found, discards the loaded module and notifies the browsers.
+func (s *docServer) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.checkSource()
+	}
+}
+
+// checkSource discards the loaded module and notifies the browsers if the source changed since the last check.
+func (s *docServer) checkSource() {
+	f := s.sourceFingerprint()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f != s.fingerprint {
+		s.fingerprint = f
+		s.docs = nil
+		s.loadErr = nil
+		close(s.changed)
+		s.changed = make(chan struct{})
+	}
+}
+
+// sourceFingerprint returns a value that changes whenever a Go source file, go.mod or go.work file or template is changed,
+// added or removed.
+func (s *docServer) sourceFingerprint() string {
+	h := sha256.New()
+	srcDir, _ := s.modFlags.srcDir()
+	_ = filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != srcDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(p, ".go") || d.Name() == "go.mod" || d.Name() == "go.work" {
+			writeFileStamp(h, p)
+		}
+		return nil
+	})
+	if cfg, err := s.modFlags.config(); err == nil {
+		for _, p := range append(s.tmplFlags.paths(cfg).list(), cfg.path) {
+			if p != "" {
+				writeFileStamp(h, p)
+			}
+		}
+	}
+	if *s.modFlags.configPath == "" {
+		writeFileStamp(h, filepath.Join(srcDir, configFileName))
+	}
+	return string(h.Sum(nil))
+}
+
+// writeFileStamp writes the name, size and modification time of a file to w.
+func writeFileStamp(w interface{ Write([]byte) (int, error) }, filePath string) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		fmt.Fprintf(w, "%s missing\n", filePath)
+		return
+	}
+	fmt.Fprintf(w, "%s %d %d\n", filePath, info.Size(), info.ModTime().UnixNano())
+}
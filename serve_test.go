@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer starts a documentation server for the module in src.
+func newTestServer(t *testing.T, src string) (*docServer, *httptest.Server) {
+	t.Helper()
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	s := newDocServer(fs)
+	if err := fs.Parse([]string{"-i", src}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.init(""); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// get returns the status code and body of the page at the path.
+func get(t *testing.T, ts *httptest.Server, p string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(ts.URL + p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestServe(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n",
+		"b/b.go": "// Package b does things.\npackage b\n\n// G does things.\nfunc G() {}\n",
+	})
+	_, ts := newTestServer(t, src)
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/", http.StatusOK, `href="b.html"`},
+		{"/index.html", http.StatusOK, `href="b.html"`},
+		{"/b.html", http.StatusOK, "func G"},
+		{"/c.html", http.StatusNotFound, "Page not found."},
+	}
+	for _, tt := range tests {
+		status, body := get(t, ts, tt.path)
+		if status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.path, status, tt.status)
+		}
+		if !strings.Contains(body, tt.want) {
+			t.Errorf("%s: page does not contain %q:\n%s", tt.path, tt.want, body)
+		}
+		if !strings.Contains(body, reloadScript) {
+			t.Errorf("%s: page does not contain the reload script", tt.path)
+		}
+	}
+}
+
+func TestServe_reload(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n",
+	})
+	s, ts := newTestServer(t, src)
+	if _, body := get(t, ts, "/a.html"); strings.Contains(body, "func H") {
+		t.Fatal("a.html contains H before it is added")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/_moddoc/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("events content type = %q", ct)
+	}
+
+	writeFiles(t, src, map[string]string{
+		"a.go": "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n\n// H is new.\nfunc H() {}\n",
+	})
+	s.checkSource()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "data: reload\n" {
+		t.Errorf("event = %q, want a reload", line)
+	}
+	if _, body := get(t, ts, "/a.html"); !strings.Contains(body, "func H") {
+		t.Error("a.html was not reloaded after its source changed")
+	}
+}
+
+func TestServe_indexFile(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod":       "module example.com/a\n",
+		"a.go":         "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n",
+		"b/b.go":       "// Package b does things.\npackage b\n\n// G does things.\nfunc G() {}\n",
+		configFileName: `{"indexFile": "main.html"}`,
+	})
+	_, ts := newTestServer(t, src)
+
+	// The first request is made before the documentation is loaded.
+	if status, body := get(t, ts, "/main.html"); status != http.StatusOK || !strings.Contains(body, `href="b.html"`) {
+		t.Errorf("/main.html: status = %d, page:\n%s", status, body)
+	}
+}
+
+func TestServe_parallelFirstRequests(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"go.mod": "module example.com/a\n",
+		"a.go":   "// Package a does things.\npackage a\n\n// F does things.\nfunc F() {}\n",
+		"b/b.go": "// Package b does things.\npackage b\n\n// G does things.\nfunc G() {}\n",
+	})
+	s, ts := newTestServer(t, src)
+
+	// Hold the first load until all the requests are made, and count the loads.
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := s.loadDocs
+	s.loadDocs = func(cfg *config) (*docs, error) {
+		loads.Add(1)
+		<-release
+		return load(cfg)
+	}
+
+	const n = 8
+	var wg sync.WaitGroup
+	statuses := make([]int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Get(ts.URL + "/b.html")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			statuses[i] = resp.StatusCode
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("%d parallel first requests loaded the module %d times, want once", n, got)
+	}
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("request %d: status = %d", i, status)
+		}
+	}
+}
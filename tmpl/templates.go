@@ -14,3 +14,19 @@ var PackageTemplate string
 //
 //go:embed index.tmpl
 var IndexTemplate string
+
+// WorkspaceTemplate is the content of the workspace template that will become the index.html file
+// of the documentation of a go.work workspace.
+//
+//go:embed workspace.tmpl
+var WorkspaceTemplate string
+
+// DeprecatedTemplate is the content of the template of the page that lists the deprecated items of a module.
+//
+//go:embed deprecated.tmpl
+var DeprecatedTemplate string
+
+// NotesTemplate is the content of the template of the page that lists the notes of a module, like BUG and TODO notes.
+//
+//go:embed notes.tmpl
+var NotesTemplate string